@@ -0,0 +1,187 @@
+package zyorm
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+//批量插入时怎么确定要插入的字段
+type InsertKeyMode int
+
+const (
+	//以第一行的 key 为准, 其他行多出的 key 忽略, 缺少的 key 插入 NULL
+	InsertKeysFirstRow InsertKeyMode = iota
+
+	//每一行的 key 都要和第一行相同, 否则返回错误, 错误中有行号和 key
+	InsertKeysStrict
+
+	//使用所有行 key 的并集, 某一行缺少的 key 插入 DEFAULT, sqlite 不支持
+	InsertKeysUnion
+)
+
+//mysql 一条语句中占位符数量的上限, 为 16 位无符号数的最大值
+const defaultMaxPlaceholders = 65535
+
+//设置本次批量插入确定字段的方式, 不设置时使用 Engine.InsertKeyMode
+func (session *Session) KeyMode(mode InsertKeyMode) *Session {
+	session.keyMode = &mode
+	return session
+}
+
+func (session *Session) insertKeyMode() InsertKeyMode {
+	if session.keyMode == nil {
+		return session.Engine.InsertKeyMode
+	}
+	return *session.keyMode
+}
+
+//VALUES 中的 DEFAULT 只有 mysql 和 postgres 支持, sqlite 使用 InsertKeysUnion 时返回错误, 防止生成错误的 sql
+func (session *Session) checkKeyModeDialect() error {
+
+	if session.insertKeyMode() != InsertKeysUnion {
+		return nil
+	}
+
+	if _, ok := session.Engine.dialect.(sqliteDialect); ok {
+		return errors.New("sqlite 不支持 InsertKeysUnion")
+	}
+
+	return nil
+}
+
+//根据 InsertKeyMode 确定批量插入的字段, 按字段名排序
+func (session *Session) insertKeys(datas []map[string]interface{}) ([]string, error) {
+
+	first := datas[0]
+
+	switch session.insertKeyMode() {
+	case InsertKeysStrict:
+
+		for i, data := range datas[1:] {
+
+			row := strconv.Itoa(i + 1)
+
+			for _, k := range sortedKeys(data) {
+				if _, ok := first[k]; !ok {
+					return nil, errors.New("第 " + row + " 行多了第 0 行没有的字段 " + k)
+				}
+			}
+
+			for _, k := range sortedKeys(first) {
+				if _, ok := data[k]; !ok {
+					return nil, errors.New("第 " + row + " 行缺少字段 " + k)
+				}
+			}
+		}
+
+	case InsertKeysUnion:
+
+		union := make(map[string]interface{}, len(first))
+		for _, data := range datas {
+			for k := range data {
+				union[k] = nil
+			}
+		}
+
+		if len(union) < 1 {
+			return nil, errors.New("参数key没有数据")
+		}
+
+		return sortedKeys(union), nil
+	}
+
+	if len(first) < 1 {
+		return nil, errors.New("参数key没有数据")
+	}
+
+	return sortedKeys(first), nil
+}
+
+//把批量插入的数据按占位符数量和 Engine.MaxAllowedPacket 分成多批, 每批生成一条 insert 语句
+//单行数据超过 MaxAllowedPacket 时单独作为一批, 由数据库返回错误
+func (session *Session) chunkRows(datas []map[string]interface{}, keys []string) ([][]map[string]interface{}, error) {
+
+	maxPlaceholders := session.Engine.MaxPlaceholders
+	if maxPlaceholders <= 0 {
+		maxPlaceholders = defaultMaxPlaceholders
+	}
+
+	//UpdateWith 的参数每条语句都有
+	for _, v := range session.duplicateData {
+		_, valueArgs := valueSql(v)
+		maxPlaceholders -= len(valueArgs)
+	}
+
+	maxBytes := session.Engine.MaxAllowedPacket
+
+	//语句中除 VALUES 之外的部分, 按字段名长度粗略估算, 多留一些给 ON DUPLICATE KEY UPDATE 等子句
+	baseSize := len(session.TableName) + 256
+	for _, key := range keys {
+		baseSize += 2 * (len(key) + 4)
+	}
+
+	var chunks [][]map[string]interface{}
+
+	start := 0
+	size := baseSize
+	placeholders := 0
+
+	for i, data := range datas {
+
+		rowSize := 3
+		rowPlaceholders := 0
+		for _, key := range keys {
+			value, ok := data[key]
+			rowSize += 1 + estimateSize(value)
+
+			if ok || session.insertKeyMode() != InsertKeysUnion {
+				_, valueArgs := valueSql(value)
+				rowPlaceholders += len(valueArgs)
+			}
+		}
+
+		if rowPlaceholders > maxPlaceholders {
+			return nil, errors.New("第 " + strconv.Itoa(i) + " 行的参数数量超过占位符数量上限")
+		}
+
+		if i > start && (placeholders+rowPlaceholders > maxPlaceholders || (maxBytes > 0 && size+rowSize > maxBytes)) {
+			chunks = append(chunks, datas[start:i])
+			start = i
+			size = baseSize
+			placeholders = 0
+		}
+
+		size += rowSize
+		placeholders += rowPlaceholders
+	}
+
+	chunks = append(chunks, datas[start:])
+
+	return chunks, nil
+}
+
+//估算一个参数发送给数据库时占用的字节数
+func estimateSize(v interface{}) int {
+
+	switch v := v.(type) {
+	case nil:
+		return 4
+	case string:
+		return len(v) + 2
+	case []byte:
+		return len(v) + 2
+	case time.Time:
+		return 28
+	case sensitiveValue:
+		return estimateSize(v.v)
+	case Expression:
+		size := len(v.sql)
+		for _, arg := range v.args {
+			size += estimateSize(arg)
+		}
+		return size
+	}
+
+	return 20
+}
@@ -0,0 +1,189 @@
+package zyorm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//n 行 a/b 两个 int 字段的数据
+func batchRows(n int) []map[string]interface{} {
+
+	datas := make([]map[string]interface{}, n)
+	for i := range datas {
+		datas[i] = map[string]interface{}{"a": i, "b": i}
+	}
+
+	return datas
+}
+
+func TestChunkRows(t *testing.T) {
+
+	//表名 t, 字段 a/b 时语句固定部分估算为 277 字节, 每行 45 字节
+	tests := []struct {
+		name             string
+		maxPlaceholders  int
+		maxAllowedPacket int
+		duplicateData    map[string]interface{}
+		rows             int
+		want             []int
+	}{
+		{"默认上限不分批", 0, 0, nil, 5, []int{5}},
+		{"占位符刚好用完", 6, 0, nil, 3, []int{3}},
+		{"占位符超过上限", 4, 0, nil, 5, []int{2, 2, 1}},
+		{"UpdateWith 的参数占用占位符", 6, 0, map[string]interface{}{"b": 1}, 5, []int{2, 2, 1}},
+		{"UpdateWith 的表达式没有参数", 6, 0, map[string]interface{}{"b": Expr("VALUES(b)")}, 5, []int{3, 2}},
+		{"数据包刚好用完", 0, 277 + 45*2, nil, 5, []int{2, 2, 1}},
+		{"数据包差一个字节", 0, 277 + 45*2 - 1, nil, 3, []int{1, 1, 1}},
+		{"单行超过数据包单独一批", 0, 100, nil, 2, []int{1, 1}},
+		{"两个限制同时生效", 6, 277 + 45*2, nil, 5, []int{2, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			engine := NewEngineFromDB(nil, MySQL)
+			engine.MaxPlaceholders = tt.maxPlaceholders
+			engine.MaxAllowedPacket = tt.maxAllowedPacket
+
+			session := engine.Table("t")
+			session.duplicateData = tt.duplicateData
+
+			datas := batchRows(tt.rows)
+
+			chunks, err := session.chunkRows(datas, []string{"a", "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			total := 0
+			for _, chunk := range chunks {
+				got = append(got, len(chunk))
+				total += len(chunk)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunk sizes = %v, want %v", got, tt.want)
+			}
+			if total != tt.rows {
+				t.Errorf("total rows = %d, want %d", total, tt.rows)
+			}
+		})
+	}
+}
+
+func TestChunkRowsTooManyPlaceholders(t *testing.T) {
+
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.MaxPlaceholders = 1
+
+	_, err := engine.Table("t").chunkRows(batchRows(1), []string{"a", "b"})
+	if err == nil {
+		t.Fatal("一行的参数超过占位符上限时应该返回错误")
+	}
+}
+
+func TestChunkRowsUnionDefault(t *testing.T) {
+
+	//InsertKeysUnion 时缺少的字段写 DEFAULT, 不占用占位符
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.MaxPlaceholders = 3
+	engine.InsertKeyMode = InsertKeysUnion
+
+	datas := []map[string]interface{}{
+		{"a": 1},
+		{"a": 2},
+		{"a": 3},
+		{"a": 4, "b": 4},
+	}
+
+	chunks, err := engine.Table("t").chunkRows(datas, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	for _, chunk := range chunks {
+		got = append(got, len(chunk))
+	}
+
+	if want := []int{3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chunk sizes = %v, want %v", got, want)
+	}
+}
+
+func TestInsertKeys(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		mode    InsertKeyMode
+		datas   []map[string]interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"第一行为准按字段名排序", InsertKeysFirstRow, []map[string]interface{}{{"b": 1, "a": 1}, {"a": 2, "c": 2}}, []string{"a", "b"}, false},
+		{"第一行没有字段", InsertKeysFirstRow, []map[string]interface{}{{}}, nil, true},
+		{"严格模式字段相同", InsertKeysStrict, []map[string]interface{}{{"a": 1, "b": 1}, {"b": 2, "a": 2}}, []string{"a", "b"}, false},
+		{"严格模式多了字段", InsertKeysStrict, []map[string]interface{}{{"a": 1}, {"a": 2, "b": 2}}, nil, true},
+		{"严格模式缺少字段", InsertKeysStrict, []map[string]interface{}{{"a": 1, "b": 1}, {"a": 2}}, nil, true},
+		{"并集", InsertKeysUnion, []map[string]interface{}{{"b": 1}, {"a": 2}, {"c": 3, "a": 3}}, []string{"a", "b", "c"}, false},
+		{"并集没有字段", InsertKeysUnion, []map[string]interface{}{{}, {}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			engine := NewEngineFromDB(nil, MySQL)
+
+			got, err := engine.Table("t").KeyMode(tt.mode).insertKeys(tt.datas)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertKeysEngineMode(t *testing.T) {
+
+	//会话没有设置时使用 Engine.InsertKeyMode
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.InsertKeyMode = InsertKeysStrict
+
+	_, err := engine.Table("t").insertKeys([]map[string]interface{}{{"a": 1}, {"b": 2}})
+	if err == nil {
+		t.Fatal("Engine.InsertKeyMode 为 InsertKeysStrict 时字段不同应该返回错误")
+	}
+}
+
+func TestInsertKeysUnionDialect(t *testing.T) {
+
+	tests := []struct {
+		dialect Dialect
+		wantErr bool
+	}{
+		{MySQL, false},
+		{PostgreSQL, false},
+		{SQLite, true},
+	}
+
+	for _, tt := range tests {
+
+		engine := NewEngineFromDB(nil, tt.dialect)
+		engine.DryRun = true
+
+		session := engine.Table("t").KeyMode(InsertKeysUnion)
+		_, err := session.InsertAll([]map[string]interface{}{{"a": 1}, {"b": 2}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%T: err = %v, wantErr %v", tt.dialect, err, tt.wantErr)
+		}
+
+		if !tt.wantErr && !strings.Contains(session.ToSQL(), "DEFAULT") {
+			t.Errorf("%T: %s", tt.dialect, session.ToSQL())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package zyorm
+
+import (
+	"errors"
+	"strings"
+)
+
+//where 条件, 可以用 And/Or/Not 任意嵌套, 传给 Where/OrWhere
+//	engine.Table("user").Where(zyorm.And(
+//		zyorm.Eq("a", 1),
+//		zyorm.Or(zyorm.Eq("b", 2), zyorm.Gt("c", 3)),
+//	))
+//相当于 a = 1 and (b = 2 or c > 3)
+type Cond interface {
+	build(session *Session) (string, []interface{}, error)
+}
+
+//map 形式的条件, 写法同 Where 的 map 参数, 多个 key 之间用 and 连接
+type M map[string]interface{}
+
+func (m M) build(session *Session) (string, []interface{}, error) {
+	return session.manageWhere(m)
+}
+
+type andCond []Cond
+
+func (c andCond) build(session *Session) (string, []interface{}, error) {
+	return joinConds(session, []Cond(c), " and ")
+}
+
+type orCond []Cond
+
+func (c orCond) build(session *Session) (string, []interface{}, error) {
+	return joinConds(session, []Cond(c), " or ")
+}
+
+type notCond struct {
+	cond Cond
+}
+
+func (c notCond) build(session *Session) (string, []interface{}, error) {
+
+	//和 And/Or 一样忽略 nil 条件
+	if c.cond == nil {
+		return "", nil, nil
+	}
+
+	where, args, err := c.cond.build(session)
+	if err != nil || len(where) < 1 {
+		return "", nil, err
+	}
+
+	return " NOT (" + where + ")", args, nil
+}
+
+//直接写 sql 的条件, sql 中的 ? 绑定 args
+func Raw(sql string, args ...interface{}) Cond {
+	return rawCond{sql: sql, args: args}
+}
+
+type rawCond struct {
+	sql  string
+	args []interface{}
+}
+
+func (c rawCond) build(session *Session) (string, []interface{}, error) {
+
+	if len(strings.TrimSpace(c.sql)) < 1 {
+		return "", nil, nil
+	}
+
+	return " " + c.sql, c.args, nil
+}
+
+//所有条件都成立
+func And(conds ...Cond) Cond {
+	return andCond(conds)
+}
+
+//任意一个条件成立
+func Or(conds ...Cond) Cond {
+	return orCond(conds)
+}
+
+//条件取反
+func Not(cond Cond) Cond {
+	return notCond{cond: cond}
+}
+
+func Eq(field string, value interface{}) Cond {
+	return M{field: value}
+}
+
+func Neq(field string, value interface{}) Cond {
+	return M{field: []interface{}{"<>", value}}
+}
+
+func Gt(field string, value interface{}) Cond {
+	return M{field: []interface{}{">", value}}
+}
+
+func Gte(field string, value interface{}) Cond {
+	return M{field: []interface{}{">=", value}}
+}
+
+func Lt(field string, value interface{}) Cond {
+	return M{field: []interface{}{"<", value}}
+}
+
+func Lte(field string, value interface{}) Cond {
+	return M{field: []interface{}{"<=", value}}
+}
+
+func Like(field string, value interface{}) Cond {
+	return M{field: []interface{}{"LIKE", value}}
+}
+
+func NotLike(field string, value interface{}) Cond {
+	return M{field: []interface{}{"NOT LIKE", value}}
+}
+
+func Regexp(field string, value interface{}) Cond {
+	return M{field: []interface{}{"REGEXP", value}}
+}
+
+func IsNull(field string) Cond {
+	return M{field: nil}
+}
+
+func IsNotNull(field string) Cond {
+	return M{field: []interface{}{"IS NOT NULL"}}
+}
+
+//values 可以是多个值, 也可以只传一个切片: In("id", []int{1, 2})
+func In(field string, values ...interface{}) Cond {
+	return M{field: []interface{}{"IN", listValue(values)}}
+}
+
+func NotIn(field string, values ...interface{}) Cond {
+	return M{field: []interface{}{"NOT IN", listValue(values)}}
+}
+
+func Between(field string, start interface{}, end interface{}) Cond {
+	return M{field: []interface{}{"BETWEEN", start, end}}
+}
+
+func NotBetween(field string, start interface{}, end interface{}) Cond {
+	return M{field: []interface{}{"NOT BETWEEN", start, end}}
+}
+
+//In/NotIn 只传了一个切片时直接使用这个切片
+func listValue(values []interface{}) interface{} {
+
+	if len(values) == 1 && values[0] != nil && !isScalar(values[0]) {
+		return values[0]
+	}
+
+	return values
+}
+
+//每个子条件加上括号后用 sep 连接, 空的子条件跳过
+func joinConds(session *Session, conds []Cond, sep string) (string, []interface{}, error) {
+
+	var wheres []string
+	var args []interface{}
+
+	for _, cond := range conds {
+		if cond == nil {
+			continue
+		}
+
+		where, condArgs, err := cond.build(session)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if len(where) < 1 {
+			continue
+		}
+
+		wheres = append(wheres, " ("+where+")")
+		args = append(args, condArgs...)
+	}
+
+	return strings.Join(wheres, sep), args, nil
+}
+
+//Where/OrWhere 的参数转成 Cond
+func toCond(wheres interface{}) (Cond, error) {
+
+	switch w := wheres.(type) {
+	case nil:
+		return M{}, nil
+	case Cond:
+		return w, nil
+	case map[string]interface{}:
+		return M(w), nil
+	}
+
+	return nil, errors.New("zyorm: where 参数只能是 map[string]interface{} 或 Cond")
+}
@@ -0,0 +1,55 @@
+package zyorm
+
+import (
+	"context"
+	"errors"
+)
+
+//context 被取消或超时后, 查询/执行返回的错误都可以用 errors.Is(err, zyorm.ErrCanceled) 判断
+//同时也可以用 errors.Is(err, context.Canceled) / errors.Is(err, context.DeadlineExceeded) 区分具体原因
+var ErrCanceled = errors.New("zyorm: context 已取消或超时")
+
+type canceledError struct {
+	err error
+}
+
+func (e *canceledError) Error() string {
+	return ErrCanceled.Error() + ": " + e.err.Error()
+}
+
+func (e *canceledError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+func (e *canceledError) Unwrap() error {
+	return e.err
+}
+
+//获取当前会话的 context, 没有设置时使用 context.Background()
+func (session *Session) context() context.Context {
+	if session.ctx == nil {
+		return context.Background()
+	}
+	return session.ctx
+}
+
+//如果错误是由 context 取消/超时引起的, 包装成 ErrCanceled, 其他错误原样返回
+func (session *Session) wrapCtxErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*canceledError); ok {
+		return err
+	}
+
+	if ctxErr := session.context().Err(); ctxErr != nil {
+		return &canceledError{err: ctxErr}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &canceledError{err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,141 @@
+package zyorm
+
+import (
+	"strconv"
+	"strings"
+)
+
+//不同数据库在 sql 语法上的差异, 生成 sql 时统一用 ? 作为占位符, 执行前再按方言转换
+type Dialect interface {
+
+	//sql.Open 时使用的驱动名, 驱动需要使用者自己 import
+	DriverName() string
+
+	//给表名/字段名加引号
+	Quote(name string) string
+
+	//第 n 个占位符, n 从 1 开始
+	Placeholder(n int) string
+
+	//根据 Limit 方法生成的 "size" 或 "offset,size" 生成 LIMIT 子句
+	LimitClause(limit string) string
+
+	//insert 后获取自增 id 的方式, 返回空字符串表示用 LastInsertId 获取, 否则返回追加在 insert 语句后的 RETURNING 子句
+	ReturningClause(pk string) string
+}
+
+var (
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) LimitClause(limit string) string {
+	return "LIMIT " + limit
+}
+
+func (mysqlDialect) ReturningClause(pk string) string {
+	return ""
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+//postgres 不支持 LIMIT offset,size 的写法
+func (postgresDialect) LimitClause(limit string) string {
+	index := strings.Index(limit, ",")
+	if index < 0 {
+		return "LIMIT " + limit
+	}
+
+	return "LIMIT " + strings.TrimSpace(limit[index+1:]) + " OFFSET " + strings.TrimSpace(limit[:index])
+}
+
+//lib/pq 等驱动不支持 LastInsertId, 需要用 RETURNING 取回主键
+func (d postgresDialect) ReturningClause(pk string) string {
+	return " RETURNING " + d.Quote(pk)
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) DriverName() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) LimitClause(limit string) string {
+	return "LIMIT " + limit
+}
+
+func (sqliteDialect) ReturningClause(pk string) string {
+	return ""
+}
+
+//把 sql 中的 ? 按方言替换成对应的占位符, 引号中的 ? 不替换
+func rebind(dialect Dialect, sqlstr string) string {
+
+	if dialect.Placeholder(1) == "?" {
+		return sqlstr
+	}
+
+	var b strings.Builder
+	b.Grow(len(sqlstr) + 8)
+
+	n := 0
+	var quote byte
+
+	for i := 0; i < len(sqlstr); i++ {
+		c := sqlstr[i]
+
+		switch {
+		case quote != 0:
+			//在引号中, 遇到相同的引号结束 ('' 这种转义会被当成结束又开始, 结果一样)
+			if c == quote {
+				quote = 0
+			}
+			b.WriteByte(c)
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			b.WriteByte(c)
+		case c == '?':
+			n++
+			b.WriteString(dialect.Placeholder(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package zyorm
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		sql     string
+		want    string
+	}{
+		{"mysql 不替换", MySQL, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"sqlite 不替换", SQLite, "SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+		{"postgres 按顺序编号", PostgreSQL, "SELECT * FROM t WHERE a = ? AND b IN (?,?)", "SELECT * FROM t WHERE a = $1 AND b IN ($2,$3)"},
+		{"单引号中的 ? 不替换", PostgreSQL, "SELECT * FROM t WHERE a = '?' AND b = ?", "SELECT * FROM t WHERE a = '?' AND b = $1"},
+		{"双引号中的 ? 不替换", PostgreSQL, `SELECT "a?" FROM t WHERE b = ?`, `SELECT "a?" FROM t WHERE b = $1`},
+		{"'' 转义后的 ? 不替换", PostgreSQL, "SELECT * FROM t WHERE a = 'it''s ?' AND b = ?", "SELECT * FROM t WHERE a = 'it''s ?' AND b = $1"},
+		{"没有占位符", PostgreSQL, "SELECT 1", "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.dialect, tt.sql); got != tt.want {
+				t.Errorf("rebind(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package zyorm
+
+//开启 DryRun, 之后这个会话的 Find/Select/Insert/Update/Delete/Count 等只生成语句不访问数据库
+//Begin/Commit/Rollback/Transaction 也不会开启真正的事务, 只记录 BEGIN/COMMIT/ROLLBACK 和 SAVEPOINT 语句
+//生成的语句用 Statements/LastStatement 获取, 查询没有结果, 影响行数和自增 id 都是 0, 拦截器仍然会执行
+//	session := engine.NewSession().DryRun()
+//	session.Table("user").Where(map[string]interface{}{"id": 1}).Update(map[string]interface{}{"name": "a"})
+//	stmt := session.LastStatement() // stmt.SQL: UPDATE user SET `name`=? WHERE  ( `id` =?), stmt.Args: [a 1]
+func (session *Session) DryRun() *Session {
+	session.dryRun = true
+	return session
+}
+
+//是否只生成 sql 不执行
+func (session *Session) isDryRun() bool {
+	return session.dryRun || session.Engine.DryRun
+}
+
+//DryRun 时生成的所有语句, 按执行的顺序
+func (session *Session) Statements() []*Statement {
+	return append([]*Statement(nil), session.statements...)
+}
+
+//DryRun 时生成的最后一条语句, 没有时返回 nil
+func (session *Session) LastStatement() *Statement {
+
+	if len(session.statements) < 1 {
+		return nil
+	}
+
+	return session.statements[len(session.statements)-1]
+}
+
+//清空 DryRun 时记录的语句
+func (session *Session) ResetStatements() {
+	session.statements = nil
+}
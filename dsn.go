@@ -0,0 +1,118 @@
+package zyorm
+
+import (
+	"crypto/tls"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//已经注册到 mysql 驱动的 TLS 配置和名字, 同一个配置只注册一次, 防止驱动中的注册表一直增长
+var (
+	tlsConfigMu    sync.Mutex
+	tlsConfigNames = make(map[*tls.Config]string)
+)
+
+//用 mysql 驱动的 Config 生成 dsn, 用户名、密码中的特殊字符不需要转义
+//先解析 ParamsStr, 再用设置了值的字段覆盖, 没有设置的字段保持 ParamsStr 或驱动的默认值
+func (conf DnsConf) DSN() (string, error) {
+
+	cfg := mysql.NewConfig()
+
+	if params := strings.TrimPrefix(conf.ParamsStr, "?"); len(params) > 0 {
+		parsed, err := mysql.ParseDSN("/?" + params)
+		if err != nil {
+			return "", err
+		}
+		cfg = parsed
+	}
+
+	cfg.User = conf.Username
+	cfg.Passwd = conf.Password
+
+	if len(conf.Socket) > 0 {
+		cfg.Net = "unix"
+		cfg.Addr = conf.Socket
+	} else {
+		cfg.Net = "tcp"
+		cfg.Addr = conf.Ip
+		if len(conf.Port) > 0 {
+			cfg.Addr += ":" + conf.Port
+		}
+	}
+
+	cfg.DBName = conf.DBName
+	if len(cfg.DBName) < 1 {
+		cfg.DBName = conf.TableName
+	}
+
+	if conf.TLS != nil {
+		name, err := registerTLSConfig(conf.TLS)
+		if err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = name
+	} else if len(conf.TLSName) > 0 {
+		cfg.TLSConfig = conf.TLSName
+	}
+
+	if conf.Loc != nil {
+		cfg.Loc = conf.Loc
+	}
+
+	if conf.ParseTime {
+		cfg.ParseTime = true
+	}
+
+	if len(conf.Collation) > 0 {
+		cfg.Collation = conf.Collation
+	}
+
+	if conf.Timeout > 0 {
+		cfg.Timeout = conf.Timeout
+	}
+
+	if conf.ReadTimeout > 0 {
+		cfg.ReadTimeout = conf.ReadTimeout
+	}
+
+	if conf.WriteTimeout > 0 {
+		cfg.WriteTimeout = conf.WriteTimeout
+	}
+
+	if conf.InterpolateParams {
+		cfg.InterpolateParams = true
+	}
+
+	if len(conf.Params) > 0 && cfg.Params == nil {
+		cfg.Params = make(map[string]string, len(conf.Params))
+	}
+	for k, v := range conf.Params {
+		cfg.Params[k] = v
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
+//把 TLS 配置注册到 mysql 驱动, 返回注册的名字, 已经注册过的配置直接返回原来的名字
+//驱动保存的是配置的指针, 注册后再修改配置也会生效
+func registerTLSConfig(config *tls.Config) (string, error) {
+
+	tlsConfigMu.Lock()
+	defer tlsConfigMu.Unlock()
+
+	if name, ok := tlsConfigNames[config]; ok {
+		return name, nil
+	}
+
+	name := "zyorm_" + strconv.Itoa(len(tlsConfigNames)+1)
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+
+	tlsConfigNames[config] = name
+
+	return name, nil
+}
@@ -0,0 +1,115 @@
+package zyorm
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDSN(t *testing.T) {
+
+	tests := []struct {
+		name string
+		conf DnsConf
+		want string
+	}{
+		{"原来的写法", DnsConf{Username: "root", Password: "root", Ip: "1.2.3.4", Port: "3306", TableName: "test", ParamsStr: "charset=utf8"}, "root:root@tcp(1.2.3.4:3306)/test?charset=utf8"},
+		{"ParamsStr 带 ?", DnsConf{Username: "root", Password: "root", Ip: "1.2.3.4", Port: "3306", DBName: "test", ParamsStr: "?charset=utf8"}, "root:root@tcp(1.2.3.4:3306)/test?charset=utf8"},
+		{"DBName 优先", DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", TableName: "old", DBName: "new"}, "root@tcp(127.0.0.1:3306)/new"},
+		{"unix socket", DnsConf{Username: "root", Password: "root", Ip: "1.2.3.4", Port: "3306", Socket: "/var/run/mysqld/mysqld.sock", DBName: "test"}, "root:root@unix(/var/run/mysqld/mysqld.sock)/test"},
+		{"TLSName", DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", DBName: "test", TLSName: "skip-verify"}, "root@tcp(127.0.0.1:3306)/test?tls=skip-verify"},
+		{"驱动参数", DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", DBName: "test", Loc: time.Local, ParseTime: true, Timeout: time.Second, Params: map[string]string{"charset": "utf8mb4"}}, "root@tcp(127.0.0.1:3306)/test?loc=Local&parseTime=true&timeout=1s&charset=utf8mb4"},
+		{"字段覆盖 ParamsStr", DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", DBName: "test", ParamsStr: "charset=utf8&parseTime=false", ParseTime: true, Params: map[string]string{"charset": "utf8mb4"}}, "root@tcp(127.0.0.1:3306)/test?parseTime=true&charset=utf8mb4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := tt.conf.DSN()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DSN() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSNPassword(t *testing.T) {
+
+	passwords := []string{"p@ss:w/rd", "a?b=c&d", "(tcp)@/", `quote'"\`, "中文密码", ""}
+
+	for _, password := range passwords {
+
+		conf := DnsConf{Username: "user@host", Password: password, Ip: "127.0.0.1", Port: "3306", DBName: "test"}
+
+		dsn, err := conf.DSN()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatalf("ParseDSN(%q): %v", dsn, err)
+		}
+
+		if cfg.User != conf.Username || cfg.Passwd != password || cfg.Addr != "127.0.0.1:3306" || cfg.DBName != "test" {
+			t.Errorf("%q 解析后为 user=%q passwd=%q addr=%q db=%q", dsn, cfg.User, cfg.Passwd, cfg.Addr, cfg.DBName)
+		}
+	}
+}
+
+func TestDSNTLS(t *testing.T) {
+
+	config := &tls.Config{ServerName: "db.example.com"}
+	conf := DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", DBName: "test", TLS: config, TLSName: "skip-verify"}
+
+	first, err := conf.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfigMu.Lock()
+	registered := len(tlsConfigNames)
+	tlsConfigMu.Unlock()
+
+	//同一个配置多次生成 dsn 只注册一次
+	second, err := conf.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfigMu.Lock()
+	if len(tlsConfigNames) != registered {
+		t.Errorf("同一个 TLS 配置注册了 %d 次", len(tlsConfigNames)-registered+1)
+	}
+	tlsConfigMu.Unlock()
+
+	if first != second {
+		t.Errorf("同一个 TLS 配置生成的 dsn 不同: %s, %s", first, second)
+	}
+
+	cfg, err := mysql.ParseDSN(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//TLS 优先于 TLSName, 名字没有注册时 ParseDSN 会返回错误
+	if cfg.TLSConfig == "skip-verify" || len(cfg.TLSConfig) < 1 {
+		t.Errorf("dsn = %s, TLSConfig = %q", first, cfg.TLSConfig)
+	}
+
+	//不同的配置使用不同的名字
+	other, err := DnsConf{Username: "root", Ip: "127.0.0.1", Port: "3306", DBName: "test", TLS: &tls.Config{}}.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other == first {
+		t.Errorf("不同的 TLS 配置生成了相同的 dsn: %s", other)
+	}
+}
@@ -16,7 +16,7 @@ func init() {
 			Password: "root",
 			Ip: "1.2.3.4",
 			Port: "3306",
-			TableName: "test",
+			DBName: "test",
 			ParamsStr: "charset=utf8",
 		})
 
@@ -0,0 +1,47 @@
+package zyorm
+
+//sql 表达式, 作为 Insert/InsertAll/Update/UpdateWith 的值时原样写入 sql, 表达式中的 ? 绑定 args
+//	engine.Table("wallet").Where(map[string]interface{}{"id": 1}).Update(map[string]interface{}{
+//		"balance":    zyorm.Expr("balance + ?", 10),
+//		"updated_at": zyorm.Expr("NOW()"),
+//	})
+type Expression struct {
+	sql  string
+	args []interface{}
+}
+
+func Expr(sql string, args ...interface{}) Expression {
+	return Expression{sql: sql, args: args}
+}
+
+//字段的值在 sql 中的写法和对应的参数, Expression 原样写入, 其他值用 ?
+func valueSql(v interface{}) (string, []interface{}) {
+
+	if e, ok := v.(Expression); ok {
+		return e.sql, e.args
+	}
+
+	return "?", []interface{}{v}
+}
+
+//update 时字段原子增加 n: col = col + n, 可以和 Update 的参数一起使用
+//	engine.Table("goods").Where(map[string]interface{}{"id": 1}).Incr("stock", 1).Update(nil)
+func (session *Session) Incr(col string, n interface{}) *Session {
+	return session.setIncr(col, " + ?", n)
+}
+
+//update 时字段原子减少 n: col = col - n
+func (session *Session) Decr(col string, n interface{}) *Session {
+	return session.setIncr(col, " - ?", n)
+}
+
+func (session *Session) setIncr(col string, op string, n interface{}) *Session {
+
+	if session.incrs == nil {
+		session.incrs = make(map[string]interface{})
+	}
+
+	session.incrs[col] = Expr(session.quote(col)+op, n)
+
+	return session
+}
@@ -1,5 +1,5 @@
 module github.com/xiui/zyorm
 
-go 1.13
+go 1.18
 
 require github.com/go-sql-driver/mysql v1.6.0
@@ -0,0 +1,169 @@
+package zyorm
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+//允许本次 update 不带 where, 更新整个表
+func (session *Session) AllowFullTable() *Session {
+	session.allowFullTable = true
+	return session
+}
+
+//更新整个表, 同 AllowFullTable().Update(data)
+func (session *Session) UpdateAll(data map[string]interface{}) (int64, error) {
+	return session.AllowFullTable().Update(data)
+}
+
+//记录新加的 where 条件是否恒为真, Where 加到当前组中, OrWhere 开始新的一组
+func (session *Session) trackAlwaysTrue(isOr bool, alwaysTrue bool) {
+
+	last := len(session.whereTrueGroups) - 1
+
+	if isOr || last < 0 {
+		session.whereTrueGroups = append(session.whereTrueGroups, alwaysTrue)
+		return
+	}
+
+	session.whereTrueGroups[last] = session.whereTrueGroups[last] && alwaysTrue
+}
+
+//开启 Engine.RejectAlwaysTrueWhere 时, 有任意一组 or 条件恒为真就返回错误
+func (session *Session) checkAlwaysTrue() error {
+
+	if !session.Engine.RejectAlwaysTrueWhere || session.allowFullTable {
+		return nil
+	}
+
+	for _, alwaysTrue := range session.whereTrueGroups {
+		if alwaysTrue {
+			return errors.New("where 条件恒为真, 会影响整个表, 确实需要时请调用 AllowFullTable")
+		}
+	}
+
+	return nil
+}
+
+//判断条件是否恒为真, 只识别常见的写法, 识别不了的当作不是恒为真
+func condAlwaysTrue(cond Cond) bool {
+
+	switch c := cond.(type) {
+	case M:
+		if len(c) < 1 {
+			return false
+		}
+		for _, v := range c {
+			if !valueAlwaysTrue(v) {
+				return false
+			}
+		}
+		return true
+
+	case andCond:
+		has := false
+		for _, child := range c {
+			if child == nil {
+				continue
+			}
+			if !condAlwaysTrue(child) {
+				return false
+			}
+			has = true
+		}
+		return has
+
+	case orCond:
+		for _, child := range c {
+			if child != nil && condAlwaysTrue(child) {
+				return true
+			}
+		}
+		return false
+
+	case rawCond:
+		return rawAlwaysTrue(c.sql)
+	}
+
+	return false
+}
+
+//LIKE '%' 这种匹配所有的条件
+func valueAlwaysTrue(v interface{}) bool {
+
+	vs, ok := v.([]interface{})
+	if !ok || len(vs) != 2 {
+		return false
+	}
+
+	t, ok := vs[0].(string)
+	if !ok || strings.ToUpper(strings.TrimSpace(t)) != "LIKE" {
+		return false
+	}
+
+	pattern, ok := vs[1].(string)
+
+	return ok && len(pattern) > 0 && strings.Trim(pattern, "%") == ""
+}
+
+//1=1, 1, true, 'a'='a' 这种写法
+func rawAlwaysTrue(sql string) bool {
+
+	s := compactSql(sql)
+
+	switch s {
+	case "1", "true", "1=1", "0=0", "1<>0", "1!=0":
+		return true
+	}
+
+	index := strings.Index(s, "=")
+	if index <= 0 || strings.ContainsAny(s, "<>!?") {
+		return false
+	}
+
+	left := s[:index]
+	right := s[index+1:]
+
+	//两边是同一个常量
+	if left != right {
+		return false
+	}
+
+	if strings.HasPrefix(left, "'") || strings.HasPrefix(left, `"`) {
+		return true
+	}
+
+	for _, c := range left {
+		if (c < '0' || c > '9') && c != '.' && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+//去掉引号外的空白和括号并转成小写, 引号中的内容保持不变, 'a b' 和 'ab' 是不同的值
+func compactSql(sql string) string {
+
+	var b strings.Builder
+	var quote rune
+
+	for _, c := range sql {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			b.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+			b.WriteRune(c)
+		case unicode.IsSpace(c) || c == '(' || c == ')':
+		default:
+			b.WriteRune(unicode.ToLower(c))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package zyorm
+
+import "testing"
+
+func TestRawAlwaysTrue(t *testing.T) {
+
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"1", true},
+		{"TRUE", true},
+		{"1=1", true},
+		{" 1 = 1 ", true},
+		{"(1=1)", true},
+		{"0=0", true},
+		{"1<>0", true},
+		{"1 != 0", true},
+		{"2=2", true},
+		{"-1.5=-1.5", true},
+		{"'a'='a'", true},
+		{`"a" = "a"`, true},
+		{"1=2", false},
+		{"'a'='b'", false},
+		{"'a b'='ab'", false},
+		{"'a b' = 'a b'", true},
+		{"'A'='a'", false},
+		{"'(a)'='a'", false},
+		{"TRUE AND 1", false},
+		{"id=id", false},
+		{"id=1", false},
+		{"1=?", false},
+		{"age>=1", false},
+		{"0", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := rawAlwaysTrue(tt.sql); got != tt.want {
+			t.Errorf("rawAlwaysTrue(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
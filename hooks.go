@@ -0,0 +1,128 @@
+package zyorm
+
+import "reflect"
+
+//模型可以选择实现下面的接口, 在对应的操作前后自动调用
+//Before 钩子返回错误时不会执行对应的操作, 错误原样返回; After 钩子返回的错误也会作为操作的结果返回
+//钩子中可以通过 session.Tx 判断是否在事务中, 查询数据时钩子在遍历结果的过程中调用, 不要在钩子中用同一个 session 执行其他语句
+
+//InsertStruct/InsertStructs 插入前调用, 可以用于校验和设置派生字段
+type BeforeInsertHook interface {
+	BeforeInsert(session *Session) error
+}
+
+//InsertStruct/InsertStructs 插入成功并写回自增 id 后调用
+type AfterInsertHook interface {
+	AfterInsert(session *Session) error
+}
+
+//UpdateStruct 更新前调用
+type BeforeUpdateHook interface {
+	BeforeUpdate(session *Session) error
+}
+
+//UpdateStruct 更新成功后调用, 有 zyversion 字段时版本号已经写回
+type AfterUpdateHook interface {
+	AfterUpdate(session *Session) error
+}
+
+//Model 指定了模型时, Delete 执行前调用
+type BeforeDeleteHook interface {
+	BeforeDelete(session *Session) error
+}
+
+//Model 指定了模型时, Delete 执行成功后调用
+type AfterDeleteHook interface {
+	AfterDelete(session *Session) error
+}
+
+//Find/Select 把一行数据写入结构体后调用
+type AfterFindHook interface {
+	AfterFind(session *Session) error
+}
+
+//获取可以调用钩子的对象, 能取地址时用指针, 这样指针接收者和值接收者的方法都能调用
+func hookTarget(v reflect.Value) interface{} {
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+func (session *Session) beforeInsert(v reflect.Value) error {
+	if hook, ok := hookTarget(v).(BeforeInsertHook); ok {
+		return hook.BeforeInsert(session)
+	}
+	return nil
+}
+
+func (session *Session) afterInsert(v reflect.Value) error {
+	if hook, ok := hookTarget(v).(AfterInsertHook); ok {
+		return hook.AfterInsert(session)
+	}
+	return nil
+}
+
+func (session *Session) beforeUpdate(v reflect.Value) error {
+	if hook, ok := hookTarget(v).(BeforeUpdateHook); ok {
+		return hook.BeforeUpdate(session)
+	}
+	return nil
+}
+
+func (session *Session) afterUpdate(v reflect.Value) error {
+	if hook, ok := hookTarget(v).(AfterUpdateHook); ok {
+		return hook.AfterUpdate(session)
+	}
+	return nil
+}
+
+func (session *Session) afterFind(v reflect.Value) error {
+	if hook, ok := hookTarget(v).(AfterFindHook); ok {
+		return hook.AfterFind(session)
+	}
+	return nil
+}
+
+//Delete 的钩子对象是 Model 指定的模型, 切片等不是单个结构体的模型不调用钩子
+func deleteHookTarget(model interface{}) interface{} {
+
+	if model == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		return model
+	}
+
+	if v.Kind() == reflect.Struct {
+		return model
+	}
+
+	return nil
+}
+
+func (session *Session) beforeDelete(model interface{}) error {
+	if hook, ok := deleteHookTarget(model).(BeforeDeleteHook); ok {
+		return hook.BeforeDelete(session)
+	}
+	return nil
+}
+
+func (session *Session) afterDelete(model interface{}) error {
+	if hook, ok := deleteHookTarget(model).(AfterDeleteHook); ok {
+		return hook.AfterDelete(session)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package zyorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+//语句的操作类型
+const (
+	OpSelect    = "select"
+	OpCount     = "count"
+	OpInsert    = "insert"
+	OpUpdate    = "update"
+	OpDelete    = "delete"
+	OpQuery     = "query"
+	OpExec      = "exec"
+	OpSavepoint = "savepoint"
+	OpBegin     = "begin"
+	OpCommit    = "commit"
+	OpRollback  = "rollback"
+)
+
+//一条将要执行的语句, 拦截器可以读取, 也可以修改 SQL/Args 后再交给 next 执行
+type Statement struct {
+
+	//操作类型, 见 OpSelect 等常量
+	Op string
+
+	//操作的表名, Prepare 的语句和事务相关的语句为空
+	Table string
+
+	//最终执行的 sql, 占位符统一为 ?, 执行前才按方言转换
+	SQL string
+
+	Args []interface{}
+
+	//执行语句的会话, 可以通过 Session.Tx 判断是否在事务中
+	Session *Session
+
+	//查询语句读取结果的函数, 为 nil 时是 exec 语句
+	scan func(rows *sql.Rows) (int64, error)
+
+	//不支持预处理的语句(savepoint), 在事务中直接执行
+	direct bool
+
+	//开启/提交/回滚事务的语句, 不执行 SQL, 调用这个函数
+	tx func(ctx context.Context) error
+}
+
+//是否是返回结果集的语句
+func (stmt *Statement) IsQuery() bool {
+	return stmt.scan != nil
+}
+
+//语句执行的结果
+type Result struct {
+
+	//exec 语句的结果, 查询语句为 nil
+	Exec sql.Result
+
+	//查询语句读取的行数
+	Rows int64
+}
+
+//执行一条语句
+type Handler func(ctx context.Context, stmt *Statement) (Result, error)
+
+//拦截器, 调用 next 继续执行, 可以用于链路追踪、统计、改写 sql、熔断等
+//不调用 next 时语句不会执行, 返回错误时操作返回这个错误; 返回 nil 错误时, exec 语句的 Result.Exec 可以为 nil,
+//这时当成影响 0 行、自增 id 为 0 处理, 查询语句当成没有数据
+//BEGIN/COMMIT/ROLLBACK 也经过拦截器, Op 为 OpBegin/OpCommit/OpRollback, BEGIN 不调用 next 时不会开启事务
+type Interceptor func(ctx context.Context, stmt *Statement, next Handler) (Result, error)
+
+//添加拦截器, 按添加的顺序从外到内执行, 需要在使用 engine 前添加, 不能和查询并发调用
+func (engine *Engine) Use(interceptors ...Interceptor) {
+	engine.interceptors = append(engine.interceptors, interceptors...)
+}
+
+//经过拦截器执行语句, 所有的 sql 都从这里执行
+func (session *Session) run(stmt *Statement) (Result, error) {
+
+	stmt.Session = session
+	session.lastStmt = stmt
+
+	handler := Handler(session.execute)
+
+	interceptors := session.Engine.interceptors
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := handler
+		handler = func(ctx context.Context, stmt *Statement) (Result, error) {
+			return interceptor(ctx, stmt, next)
+		}
+	}
+
+	start := time.Now()
+
+	ret, err := handler(session.context(), stmt)
+	err = session.wrapCtxErr(err)
+
+	//拦截器没有调用 next 直接返回时没有 sql.Result, 用空结果代替, 防止调用方使用时 panic
+	if err == nil && stmt.scan == nil && ret.Exec == nil {
+		ret.Exec = emptyResult{}
+	}
+
+	session.logStatement(stmt, ret, err, time.Since(start))
+
+	return ret, err
+}
+
+//Find/Select 查询的表名, 和 getSqlStr 一样以结构体对应的表为准
+func (session *Session) selectTable(t reflect.Type) string {
+
+	tableInfo, err := session.Engine.getTable(t)
+	if err != nil {
+		return session.TableName
+	}
+
+	return tableInfo.Name
+}
+
+//最内层的 Handler, 预处理后执行, 查询语句在这里读取完结果
+func (session *Session) execute(ctx context.Context, stmt *Statement) (Result, error) {
+
+	//DryRun 时只记录语句, 不访问数据库
+	if session.isDryRun() {
+		session.statements = append(session.statements, stmt)
+		return Result{Exec: emptyResult{}}, nil
+	}
+
+	if stmt.tx != nil {
+		return Result{}, stmt.tx(ctx)
+	}
+
+	if stmt.direct {
+		ret, err := session.Tx.ExecContext(ctx, rebind(session.Engine.dialect, stmt.SQL), stmt.Args...)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{Exec: ret}, nil
+	}
+
+	stmtIns, err := session.prepareStmt(ctx, stmt.SQL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer stmtIns.Close()
+
+	if stmt.scan == nil {
+		ret, err := stmtIns.ExecContext(ctx, stmt.Args...)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{Exec: ret}, nil
+	}
+
+	rows, err := stmtIns.QueryContext(ctx, stmt.Args...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer rows.Close()
+
+	n, err := stmt.scan(rows)
+	if err != nil {
+		return Result{Rows: n}, err
+	}
+
+	//遍历中途 context 被取消等错误, 只能在循环结束后通过 rows.Err 获取
+	if err = rows.Err(); err != nil {
+		return Result{Rows: n}, err
+	}
+
+	return Result{Rows: n}, nil
+}
+
+//没有执行的 exec 语句的结果, 影响 0 行, 自增 id 为 0
+type emptyResult struct{}
+
+func (emptyResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (emptyResult) RowsAffected() (int64, error) {
+	return 0, nil
+}
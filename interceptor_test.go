@@ -0,0 +1,95 @@
+package zyorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+//记录经过拦截器的语句
+func recordOps(engine *Engine) *[]string {
+
+	var ops []string
+
+	engine.Use(func(ctx context.Context, stmt *Statement, next Handler) (Result, error) {
+		ops = append(ops, stmt.Op+" "+stmt.SQL)
+		return next(ctx, stmt)
+	})
+
+	return &ops
+}
+
+//事务的开启/提交/回滚和其他语句一样经过拦截器
+func TestInterceptorTransaction(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		fnErr  error
+		want   []string
+	}{
+		{"提交", false, nil, []string{"begin BEGIN", "insert INSERT INTO t(`a`) VALUES (?)", "commit COMMIT"}},
+		{"回滚", false, errors.New("fail"), []string{"begin BEGIN", "insert INSERT INTO t(`a`) VALUES (?)", "rollback ROLLBACK"}},
+		{"DryRun", true, nil, []string{"begin BEGIN", "insert INSERT INTO t(`a`) VALUES (?)", "commit COMMIT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db, d := openStub(t)
+			engine := NewEngineFromDB(db, MySQL)
+			engine.DryRun = tt.dryRun
+
+			ops := recordOps(engine)
+
+			err := engine.Transaction(func(session *Session) error {
+				if _, err := session.Table("t").Insert(map[string]interface{}{"a": 1}); err != nil {
+					return err
+				}
+				return tt.fnErr
+			})
+			if err != tt.fnErr {
+				t.Fatalf("err = %v, want %v", err, tt.fnErr)
+			}
+
+			if !reflect.DeepEqual(*ops, tt.want) {
+				t.Errorf("ops = %q, want %q", *ops, tt.want)
+			}
+
+			//DryRun 时不访问数据库
+			if tt.dryRun && len(d.queries()) > 0 {
+				t.Errorf("DryRun 时执行了 %q", d.queries())
+			}
+		})
+	}
+}
+
+//拦截器返回错误时不开启事务
+func TestInterceptorRejectBegin(t *testing.T) {
+
+	db, d := openStub(t)
+	engine := NewEngineFromDB(db, MySQL, WithLogger(NopLogger{}))
+
+	reject := errors.New("reject")
+	engine.Use(func(ctx context.Context, stmt *Statement, next Handler) (Result, error) {
+		if stmt.Op == OpBegin {
+			return Result{}, reject
+		}
+		return next(ctx, stmt)
+	})
+
+	called := false
+	err := engine.Transaction(func(session *Session) error {
+		called = true
+		return nil
+	})
+
+	if err != reject || called {
+		t.Errorf("err = %v, called = %v", err, called)
+	}
+
+	if queries := d.queries(); len(queries) > 0 {
+		t.Errorf("queries = %q", queries)
+	}
+}
@@ -0,0 +1,141 @@
+package zyorm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+//日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + fmt.Sprint(int(l)) + ")"
+}
+
+//日志中的结构化字段, 语句相关的日志会带上 table/sql/args/duration/rows/error
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//日志接口, 用 Engine.SetLogger 替换默认的日志输出
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields ...Field)
+}
+
+//用标准库 log.Logger 输出, 低于 level 的日志不输出, 字段以 key=value 的格式追加在消息后面
+type StdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+//l 为 nil 时输出到 stderr
+func NewStdLogger(l *log.Logger, level LogLevel) *StdLogger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &StdLogger{logger: l, level: level}
+}
+
+func (l *StdLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("[zyorm] ")
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for _, field := range fields {
+		b.WriteString(" ")
+		b.WriteString(field.Key)
+		b.WriteString("=")
+		b.WriteString(fmt.Sprint(field.Value))
+	}
+
+	l.logger.Println(b.String())
+}
+
+//不输出任何日志
+type NopLogger struct{}
+
+func (NopLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {}
+
+//没有设置日志时使用, 和原来一样输出到标准库 log
+var defaultLogger Logger = NewStdLogger(log.Default(), LevelInfo)
+
+//设置日志, 为 nil 时恢复默认日志, 不需要日志时设置 NopLogger{}
+func (engine *Engine) SetLogger(logger Logger) {
+	engine.logger = logger
+}
+
+//当前使用的日志
+func (engine *Engine) Logger() Logger {
+	if engine.logger == nil {
+		return defaultLogger
+	}
+	return engine.logger
+}
+
+//用会话的 context 输出日志
+func (session *Session) log(level LogLevel, msg string, fields ...Field) {
+	session.Engine.Logger().Log(session.context(), level, msg, fields...)
+}
+
+//输出语句的日志: 出错时输出错误, 超过 SlowThreshold 时输出慢查询, 设置了 ShowSql 时输出所有语句
+func (session *Session) logStatement(stmt *Statement, ret Result, err error, duration time.Duration) {
+
+	engine := session.Engine
+	slow := engine.SlowThreshold > 0 && duration >= engine.SlowThreshold
+
+	if err == nil && !slow && !engine.ShowSql {
+		return
+	}
+
+	rows := ret.Rows
+	if ret.Exec != nil {
+		rows, _ = ret.Exec.RowsAffected()
+	}
+
+	fields := []Field{
+		{"op", stmt.Op},
+		{"table", stmt.Table},
+		{"sql", stmt.SQL},
+		{"args", redactArgs(stmt.Args)},
+		{"duration", duration},
+		{"rows", rows},
+	}
+
+	switch {
+	case err != nil:
+		session.log(LevelError, "sql error", append(fields, Field{"error", err})...)
+	case slow:
+		session.log(LevelWarn, "slow sql", append(fields, Field{"threshold", engine.SlowThreshold})...)
+	default:
+		session.log(LevelInfo, stmt.String(), fields...)
+	}
+}
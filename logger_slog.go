@@ -0,0 +1,52 @@
+//go:build go1.21
+
+package zyorm
+
+import (
+	"context"
+	"log/slog"
+)
+
+//把日志输出到 log/slog, 字段转成 slog.Attr
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+//l 为 nil 时使用 slog.Default()
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{logger: l}
+}
+
+func (l *SlogLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+
+	slogLevel := slogLevel(level)
+	if !l.logger.Enabled(ctx, slogLevel) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		if err, ok := field.Value.(error); ok {
+			attrs = append(attrs, slog.String(field.Key, err.Error()))
+			continue
+		}
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+
+	l.logger.LogAttrs(ctx, slogLevel, msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,102 @@
+package zyorm
+
+import (
+	"database/sql"
+	"time"
+)
+
+//创建 engine 时的选项, 在 engine 创建后按顺序执行, 可以覆盖默认设置
+//	engine, err := zyorm.NewEngine(conf, zyorm.WithMaxOpen(50), zyorm.WithConnMaxLifetime(time.Hour))
+type Option func(engine *Engine)
+
+//最大打开的连接数, <= 0 时不限制
+func WithMaxOpen(n int) Option {
+	return func(engine *Engine) {
+		if engine.db != nil {
+			engine.db.SetMaxOpenConns(n)
+		}
+	}
+}
+
+//最大空闲连接数, <= 0 时不保留空闲连接
+func WithMaxIdle(n int) Option {
+	return func(engine *Engine) {
+		if engine.db != nil {
+			engine.db.SetMaxIdleConns(n)
+		}
+	}
+}
+
+//连接最长的使用时间, 超过后关闭重新连接, <= 0 时不限制
+//数据库或中间件会主动断开长时间的连接时需要设置成比它小的值
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(engine *Engine) {
+		if engine.db != nil {
+			engine.db.SetConnMaxLifetime(d)
+		}
+	}
+}
+
+//连接最长的空闲时间, 超过后关闭, <= 0 时不限制
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(engine *Engine) {
+		if engine.db != nil {
+			engine.db.SetConnMaxIdleTime(d)
+		}
+	}
+}
+
+//生成 sql 时使用的方言, 用于兼容 mysql 等协议但语法有差异的数据库
+func WithDialect(dialect Dialect) Option {
+	return func(engine *Engine) {
+		if dialect != nil {
+			engine.dialect = dialect
+		}
+	}
+}
+
+//日志, 同 SetLogger
+func WithLogger(logger Logger) Option {
+	return func(engine *Engine) {
+		engine.SetLogger(logger)
+	}
+}
+
+//慢查询时间, 同 Engine.SlowThreshold
+func WithSlowThreshold(d time.Duration) Option {
+	return func(engine *Engine) {
+		engine.SlowThreshold = d
+	}
+}
+
+//添加拦截器, 同 Use
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(engine *Engine) {
+		engine.Use(interceptors...)
+	}
+}
+
+//底层的 *sql.DB, 用于 zyorm 没有提供的功能, 不要调用它的 Close, 请用 Engine.Close
+func (engine *Engine) DB() *sql.DB {
+	return engine.db
+}
+
+//连接池的统计信息
+func (engine *Engine) Stats() sql.DBStats {
+
+	if engine.db == nil {
+		return sql.DBStats{}
+	}
+
+	return engine.db.Stats()
+}
+
+//关闭数据库连接, 关闭后 engine 不能再使用
+func (engine *Engine) Close() error {
+
+	if engine.db == nil {
+		return nil
+	}
+
+	return engine.db.Close()
+}
@@ -0,0 +1,205 @@
+package zyorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+//One 查到多条数据时返回的错误
+var ErrMultipleRows = errors.New("zyorm: 查询结果不止一条")
+
+//带结果类型的查询, T 为 model 结构体或结构体指针, 结果类型在编译时检查
+//	users, err := zyorm.Query[User](engine).Where(map[string]interface{}{"age": 18}).All(ctx)
+type TypedQuery[T any] struct {
+	session *Session
+}
+
+//用 engine 新建会话创建查询
+func Query[T any](engine *Engine) *TypedQuery[T] {
+	return &TypedQuery[T]{session: engine.createSession()}
+}
+
+//在已有的会话上创建查询, 可以用在事务中
+func QuerySession[T any](session *Session) *TypedQuery[T] {
+	return &TypedQuery[T]{session: session}
+}
+
+//底层使用的会话
+func (q *TypedQuery[T]) Session() *Session {
+	return q.session
+}
+
+func (q *TypedQuery[T]) Fields(fields string) *TypedQuery[T] {
+	q.session.Fields(fields)
+	return q
+}
+
+func (q *TypedQuery[T]) Where(wheres interface{}) *TypedQuery[T] {
+	q.session.Where(wheres)
+	return q
+}
+
+func (q *TypedQuery[T]) OrWhere(wheres interface{}) *TypedQuery[T] {
+	q.session.OrWhere(wheres)
+	return q
+}
+
+func (q *TypedQuery[T]) Join(join string, args ...interface{}) *TypedQuery[T] {
+	q.session.Join(join, args...)
+	return q
+}
+
+func (q *TypedQuery[T]) Order(order string) *TypedQuery[T] {
+	q.session.Order(order)
+	return q
+}
+
+func (q *TypedQuery[T]) Group(group string) *TypedQuery[T] {
+	q.session.Group(group)
+	return q
+}
+
+func (q *TypedQuery[T]) Limit(args ...interface{}) *TypedQuery[T] {
+	q.session.Limit(args...)
+	return q
+}
+
+//查询所有数据, 没有数据时返回空切片
+func (q *TypedQuery[T]) All(ctx context.Context) ([]T, error) {
+
+	elemT, isPtr, err := q.structType()
+	if err != nil {
+		q.session.clearSession()
+		return nil, err
+	}
+
+	if !isPtr {
+		result := []T{}
+
+		err = q.session.Context(ctx).Select(&result)
+		if err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}
+
+	//T 是结构体指针时, 查询到结构体切片后再取每个元素的地址
+	values := reflect.New(reflect.SliceOf(elemT))
+
+	err = q.session.Context(ctx).Select(values.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	values = values.Elem()
+	result := make([]T, values.Len())
+	for i := range result {
+		result[i] = values.Index(i).Addr().Interface().(T)
+	}
+
+	return result, nil
+}
+
+//查询第一条数据, 没有数据时第二个返回值为 false
+func (q *TypedQuery[T]) First(ctx context.Context) (T, bool, error) {
+
+	var result T
+
+	elemT, isPtr, err := q.structType()
+	if err != nil {
+		q.session.clearSession()
+		return result, false, err
+	}
+
+	if !isPtr {
+		has, err := q.session.Context(ctx).Find(&result)
+		return result, has, err
+	}
+
+	value := reflect.New(elemT)
+
+	has, err := q.session.Context(ctx).Find(value.Interface())
+	if err != nil || !has {
+		return result, has, err
+	}
+
+	return value.Interface().(T), true, nil
+}
+
+//查询有且只有一条的数据, 没有数据返回 sql.ErrNoRows, 多于一条返回 ErrMultipleRows
+func (q *TypedQuery[T]) One(ctx context.Context) (T, error) {
+
+	var zero T
+
+	result, err := q.Limit(2).All(ctx)
+	if err != nil {
+		return zero, err
+	}
+
+	switch len(result) {
+	case 0:
+		return zero, sql.ErrNoRows
+	case 1:
+		return result[0], nil
+	}
+
+	return zero, ErrMultipleRows
+}
+
+//查询数量, 和 All/First 一样使用 T 对应的表, 不统计软删除的数据
+func (q *TypedQuery[T]) Count(ctx context.Context) (int64, error) {
+
+	elemT, _, err := q.structType()
+	if err != nil {
+		q.session.clearSession()
+		return 0, err
+	}
+
+	tableInfo, err := q.session.Engine.getTable(elemT)
+	if err != nil {
+		q.session.clearSession()
+		return 0, err
+	}
+
+	//结构体查询总是使用结构体对应的表, 会话上用 Table 设置的表名不生效, 这里保持一致
+	q.session.TableName = tableInfo.Name
+
+	return q.session.Context(ctx).Model(new(T)).Count()
+}
+
+//T 对应的结构体类型, T 是否是结构体指针, T 不是结构体或结构体指针时返回错误
+func (q *TypedQuery[T]) structType() (reflect.Type, bool, error) {
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, false, errors.New("Query 的类型参数不是结构体或结构体指针")
+	}
+
+	return t, isPtr, nil
+}
+
+//把查询结果按 key 函数的返回值转成 map, key 相同时后面的覆盖前面的
+//go 的方法不能有自己的类型参数, 所以写成函数: zyorm.Map(ctx, zyorm.Query[User](engine), func(u User) int64 { return u.Id })
+func Map[T any, K comparable](ctx context.Context, q *TypedQuery[T], key func(T) K) (map[K]T, error) {
+
+	result, err := q.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[K]T, len(result))
+	for _, item := range result {
+		m[key(item)] = item
+	}
+
+	return m, nil
+}
@@ -0,0 +1,58 @@
+package zyorm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+//All/First/Count 使用同一个表, 会话上用 Table 设置的表名不影响
+func TestTypedQueryTable(t *testing.T) {
+
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.DryRun = true
+
+	ctx := context.Background()
+
+	session := engine.Table("users")
+	q := QuerySession[*softUser](session)
+
+	if _, err := q.All(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	session.Table("users")
+	if _, _, err := q.First(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	session.Table("users")
+	if _, err := q.Count(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stmts := session.Statements()
+	if len(stmts) != 3 {
+		t.Fatalf("statements = %d, want 3", len(stmts))
+	}
+
+	for _, stmt := range stmts {
+		if !strings.Contains(stmt.SQL, "FROM softuser ") || stmt.Table != "softuser" {
+			t.Errorf("%s: table = %s, SQL = %s", stmt.Op, stmt.Table, stmt.SQL)
+		}
+	}
+}
+
+func TestTypedQueryNotStruct(t *testing.T) {
+
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.DryRun = true
+
+	if _, err := Query[int](engine).All(context.Background()); err == nil {
+		t.Error("T 不是结构体时 All 应该返回错误")
+	}
+
+	if _, err := Query[*string](engine).Count(context.Background()); err == nil {
+		t.Error("T 不是结构体指针时 Count 应该返回错误")
+	}
+}
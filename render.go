@@ -0,0 +1,208 @@
+package zyorm
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//敏感数据输出 sql 和日志时显示的内容
+const redacted = "'***'"
+
+//敏感数据, 执行时使用原来的值, 输出 sql 和日志时隐藏
+type sensitiveValue struct {
+	v interface{}
+}
+
+//标记敏感数据, 可以作为 Insert/Update/Where 的值, ShowSql/ToSQL/日志中显示为 '***'
+//结构体字段可以用 zysensitive:"true" 标记, InsertStruct/UpdateStruct 时自动处理
+func Sensitive(v interface{}) interface{} {
+	if _, ok := v.(sensitiveValue); ok {
+		return v
+	}
+	return sensitiveValue{v: v}
+}
+
+func (s sensitiveValue) Value() (driver.Value, error) {
+	return driver.DefaultParameterConverter.ConvertValue(s.v)
+}
+
+func (s sensitiveValue) String() string {
+	return redacted
+}
+
+//日志中使用的参数, 敏感数据替换成 '***'
+func redactArgs(args []interface{}) []interface{} {
+
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		if _, ok := arg.(sensitiveValue); ok {
+			out[i] = redacted
+			continue
+		}
+		out[i] = arg
+	}
+
+	return out
+}
+
+//把参数代入 sql 得到可以直接阅读的 sql, 只用于输出, 不能用来执行
+//引号中的 ? 不会被替换, 字符串会加引号并转义, 敏感数据显示为 '***'
+func (stmt *Statement) String() string {
+
+	dialect := MySQL
+	loc := time.UTC
+	if stmt.Session != nil {
+		dialect = stmt.Session.Engine.dialect
+		loc = stmt.Session.Engine.location()
+	}
+
+	return interpolate(dialect, loc, stmt.SQL, stmt.Args)
+}
+
+//返回会话最后执行的一条语句代入参数后的 sql, 没有执行过语句时返回空字符串
+func (session *Session) ToSQL() string {
+
+	if session.lastStmt == nil {
+		return ""
+	}
+
+	return session.lastStmt.String()
+}
+
+//把 sql 中引号外的 ? 依次替换成参数的字面量, 时间转成 loc 时区后输出, 和驱动写入的值一致
+func interpolate(dialect Dialect, loc *time.Location, sqlstr string, args []interface{}) string {
+
+	_, backslash := dialect.(mysqlDialect)
+
+	var b strings.Builder
+	b.Grow(len(sqlstr) + len(args)*8)
+
+	n := 0
+	var quote byte
+
+	for i := 0; i < len(sqlstr); i++ {
+		c := sqlstr[i]
+
+		switch {
+		case quote != 0:
+			b.WriteByte(c)
+			//mysql 字符串中的 \ 是转义符, 后面的字符原样输出
+			if backslash && c == '\\' && quote != '`' && i+1 < len(sqlstr) {
+				i++
+				b.WriteByte(sqlstr[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			b.WriteByte(c)
+		case c == '?':
+			if n < len(args) {
+				b.WriteString(literal(dialect, loc, args[n]))
+			} else {
+				b.WriteByte(c)
+			}
+			n++
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+//参数在 sql 中的字面量
+func literal(dialect Dialect, loc *time.Location, v interface{}) string {
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case sensitiveValue:
+		return redacted
+	case string:
+		return quoteString(dialect, v)
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		if _, ok := dialect.(postgresDialect); ok {
+			return `'\x` + hex.EncodeToString(v) + `'`
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		//mysql 驱动写入前会把时间转成 dsn 中 loc 的时区
+		return "'" + v.In(loc).Format("2006-01-02 15:04:05.999999") + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case driver.Valuer:
+		//值为 nil 的指针实现了 Valuer 时调用会 panic, 当成 NULL
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL"
+		}
+		value, err := v.Value()
+		if err != nil {
+			return "?"
+		}
+		return literal(dialect, loc, value)
+	}
+
+	//指针取指向的值, 其他类型按字符串处理
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return literal(dialect, loc, rv.Elem().Interface())
+	}
+
+	//自定义的整数/字符串等类型转成基础类型
+	if value, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil && reflect.TypeOf(value) != rv.Type() {
+		return literal(dialect, loc, value)
+	}
+
+	return quoteString(dialect, fmt.Sprint(v))
+}
+
+//字符串加单引号, ' 写成 '', mysql 中 \ 也需要转义
+func quoteString(dialect Dialect, s string) string {
+
+	s = strings.Replace(s, "'", "''", -1)
+
+	if _, ok := dialect.(mysqlDialect); ok {
+		s = strings.Replace(s, `\`, `\\`, -1)
+	}
+
+	return "'" + s + "'"
+}
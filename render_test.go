@@ -0,0 +1,120 @@
+package zyorm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type renderID int64
+
+func TestInterpolate(t *testing.T) {
+
+	name := "tom"
+	var nilName *string
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		sql     string
+		args    []interface{}
+		want    string
+	}{
+		{"整数和字符串", MySQL, "SELECT * FROM t WHERE id = ? AND name = ?", []interface{}{1, "tom"}, "SELECT * FROM t WHERE id = 1 AND name = 'tom'"},
+		{"单引号中的 ? 不替换", MySQL, "SELECT '?' FROM t WHERE id = ?", []interface{}{1}, "SELECT '?' FROM t WHERE id = 1"},
+		{"双引号和反引号中的 ? 不替换", MySQL, "SELECT `a?`, \"b?\" FROM t WHERE id = ?", []interface{}{1}, "SELECT `a?`, \"b?\" FROM t WHERE id = 1"},
+		{"mysql 字符串中转义的引号", MySQL, `SELECT 'it\'s ?' FROM t WHERE id = ?`, []interface{}{1}, `SELECT 'it\'s ?' FROM t WHERE id = 1`},
+		{"'' 转义的引号", MySQL, "SELECT 'it''s ?' FROM t WHERE id = ?", []interface{}{1}, "SELECT 'it''s ?' FROM t WHERE id = 1"},
+		{"postgres 的 \\ 不是转义符", PostgreSQL, `SELECT 'a\' FROM t WHERE id = ?`, []interface{}{1}, `SELECT 'a\' FROM t WHERE id = 1`},
+		{"mysql 参数中的引号和反斜杠", MySQL, "SELECT * FROM t WHERE name = ?", []interface{}{`a'b\'--`}, `SELECT * FROM t WHERE name = 'a''b\\''--'`},
+		{"postgres 参数中的引号和反斜杠", PostgreSQL, "SELECT * FROM t WHERE name = ?", []interface{}{`a'b\`}, `SELECT * FROM t WHERE name = 'a''b\'`},
+		{"参数不够时保留 ?", MySQL, "SELECT * FROM t WHERE a = ? AND b = ?", []interface{}{1}, "SELECT * FROM t WHERE a = 1 AND b = ?"},
+		{"NULL", MySQL, "UPDATE t SET a = ?", []interface{}{nil}, "UPDATE t SET a = NULL"},
+		{"指针", MySQL, "UPDATE t SET a = ?, b = ?", []interface{}{&name, nilName}, "UPDATE t SET a = 'tom', b = NULL"},
+		{"敏感数据", MySQL, "UPDATE t SET password = ? WHERE id = ?", []interface{}{Sensitive("secret"), 1}, "UPDATE t SET password = '***' WHERE id = 1"},
+		{"时间", MySQL, "UPDATE t SET a = ?", []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)}, "UPDATE t SET a = '2020-01-02 03:04:05.6'"},
+		{"mysql 字节", MySQL, "UPDATE t SET a = ?, b = ?", []interface{}{[]byte{0xde, 0xad}, []byte(nil)}, "UPDATE t SET a = X'dead', b = NULL"},
+		{"postgres 字节", PostgreSQL, "UPDATE t SET a = ?", []interface{}{[]byte{0xde, 0xad}}, `UPDATE t SET a = '\xdead'`},
+		{"bool 和浮点数", MySQL, "UPDATE t SET a = ?, b = ?", []interface{}{true, 1.5}, "UPDATE t SET a = TRUE, b = 1.5"},
+		{"自定义整数类型", MySQL, "SELECT * FROM t WHERE id = ?", []interface{}{renderID(7)}, "SELECT * FROM t WHERE id = 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpolate(tt.dialect, time.UTC, tt.sql, tt.args); got != tt.want {
+				t.Errorf("interpolate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+
+	args := []interface{}{1, Sensitive("secret")}
+
+	got := redactArgs(args)
+	if got[0] != 1 || got[1] != redacted {
+		t.Errorf("redactArgs() = %v", got)
+	}
+
+	//执行时使用原来的值
+	value, err := args[1].(sensitiveValue).Value()
+	if err != nil || value != "secret" {
+		t.Errorf("Value() = %v, %v", value, err)
+	}
+}
+
+func TestToSQL(t *testing.T) {
+
+	engine := NewEngineFromDB(nil, PostgreSQL)
+
+	session := engine.Table("users").DryRun()
+	if got := session.ToSQL(); got != "" {
+		t.Errorf("没有执行语句时 ToSQL() = %q", got)
+	}
+
+	_, err := session.Where(map[string]interface{}{"name": "o'neil"}).Update(map[string]interface{}{"password": Sensitive("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := session.ToSQL()
+	if !strings.Contains(got, "'***'") || strings.Contains(got, "secret") || !strings.Contains(got, "'o''neil'") {
+		t.Errorf("ToSQL() = %s", got)
+	}
+}
+
+//时间按 engine 的时区输出, 和驱动写入的值一致
+func TestToSQLTimeZone(t *testing.T) {
+
+	east8 := time.FixedZone("east8", 8*3600)
+	value := time.Date(2020, 1, 2, 8, 0, 0, 0, east8)
+
+	tests := []struct {
+		name     string
+		timeZone *time.Location
+		want     string
+	}{
+		{"默认 UTC", nil, "'2020-01-02 00:00:00'"},
+		{"TimeZone", east8, "'2020-01-02 08:00:00'"},
+		{"其他时区", time.FixedZone("west5", -5*3600), "'2020-01-01 19:00:00'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			engine := NewEngineFromDB(nil, MySQL)
+			engine.DryRun = true
+			engine.TimeZone = tt.timeZone
+
+			session := engine.Table("t")
+			if _, err := session.Insert(map[string]interface{}{"a": value}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := session.ToSQL(); got != "INSERT INTO t(`a`) VALUES ("+tt.want+")" {
+				t.Errorf("ToSQL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
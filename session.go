@@ -1,10 +1,12 @@
 package zyorm
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
-	"log"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +16,18 @@ import (
 type Session struct {
 
 	Tx *sql.Tx
+	txDone bool	//事务已经提交或回滚
+	txDepth int	//嵌套事务的层数, 0 表示最外层事务, 每层对应一个 SAVEPOINT zyorm_N
 
 	TableName string
 
+	//主键字段名, 不需要 LastInsertId 的方言(如 postgres) insert 时用它生成 RETURNING 子句, 默认 id
+	pk string
+
 	Engine *Engine
 
+	ctx context.Context	//通过 Context 方法设置, 为空时使用 context.Background()
+
 	fields string
 	where string
 	limit string
@@ -33,20 +42,105 @@ type Session struct {
 
 	prepare string	//直接写 sql 时使用
 
+	verb string	//INSERT / INSERT IGNORE / REPLACE, 为空时是 INSERT
+	duplicate bool	//是否生成 ON DUPLICATE KEY UPDATE
+	duplicateCols []string	//ON DUPLICATE KEY UPDATE col=VALUES(col) 的字段, 为空时更新所有插入的字段
+	duplicateData map[string]interface{}	//ON DUPLICATE KEY UPDATE col=? 的字段和值
+
+	keyMode *InsertKeyMode	//批量插入时怎么确定字段, 为 nil 时使用 Engine.InsertKeyMode
+
+	incrs map[string]interface{}	//Incr/Decr 设置的字段, update 时和参数合并
+
+	model interface{}	//Model 指定的模型, 用于软删除等需要表信息的操作, 只对下一条语句有效
+	unscoped bool	//不处理软删除, 查询包含已删除的数据, delete 真正删除
+
+	allowFullTable bool	//允许不带 where 的 update
+	whereTrueGroups []bool	//where 按 or 分组后, 每组是否恒为真, 用于 Engine.RejectAlwaysTrueWhere
+
+	err error	//链式调用中产生的错误, 执行时返回
+
+	lastStmt *Statement	//最后执行的语句, 用于 ToSQL
+
+	dryRun bool	//只生成 sql 不执行, 见 DryRun
+	dryRunTx bool	//DryRun 时调用了 Begin, 没有真正的 Tx
+	statements []*Statement	//DryRun 时生成的语句
+
 }
 
+//设置本次会话使用的 context, 之后的查询/执行/事务都会带上它, 用于取消慢查询或设置超时
+func (session *Session) Context(ctx context.Context) *Session {
+	session.ctx = ctx
+	return session
+}
+
+//开启事务, 已经在事务中时创建 SAVEPOINT zyorm_N 作为嵌套事务
 func (session *Session) Begin() error {
-	var err error
-	session.Tx, err = session.Engine.db.Begin()
+	return session.BeginTx(nil)
+}
+
+//按指定的隔离级别/只读等选项开启事务, opts 为 nil 时使用数据库默认设置
+//已经在事务中时创建 SAVEPOINT, 嵌套事务沿用外层事务的选项, opts 不生效
+func (session *Session) BeginTx(opts *sql.TxOptions) error {
+
+	if session.Tx != nil || session.dryRunTx {
+		err := session.execSavepoint("SAVEPOINT", session.txDepth+1)
+		if err != nil {
+			return err
+		}
+		session.txDepth++
+		return nil
+	}
+
+	//和其他语句一样经过拦截器, DryRun 时不开启真正的事务, 只记录语句
+	dryRun := session.isDryRun()
+
+	_, err := session.run(&Statement{Op: OpBegin, SQL: "BEGIN", tx: func(ctx context.Context) error {
+		tx, err := session.Engine.db.BeginTx(ctx, opts)
+		if err != nil {
+			return err
+		}
+		session.Tx = tx
+		return nil
+	}})
+
+	session.txDone = false
+	if err == nil && dryRun {
+		session.dryRunTx = true
+	}
+
 	return err
 }
 
+//回滚事务, 嵌套事务中只回滚到对应的 SAVEPOINT
 func (session *Session) Rollback() error {
-	return session.Tx.Rollback()
+
+	if err := session.checkTx(); err != nil {
+		return err
+	}
+
+	if session.txDepth > 0 {
+		err := session.execSavepoint("ROLLBACK TO SAVEPOINT", session.txDepth)
+		session.txDepth--
+		return err
+	}
+
+	return session.endTx(OpRollback, "ROLLBACK", (*sql.Tx).Rollback)
 }
 
+//提交事务, 嵌套事务中只释放对应的 SAVEPOINT, 最外层提交时才真正提交
 func (session *Session) Commit() error {
-	return session.Tx.Commit()
+
+	if err := session.checkTx(); err != nil {
+		return err
+	}
+
+	if session.txDepth > 0 {
+		err := session.execSavepoint("RELEASE SAVEPOINT", session.txDepth)
+		session.txDepth--
+		return err
+	}
+
+	return session.endTx(OpCommit, "COMMIT", (*sql.Tx).Commit)
 }
 
 
@@ -55,6 +149,18 @@ func (session *Session) Table(tableName string) *Session {
 	return session
 }
 
+func (session *Session) Pk(pk string) *Session {
+	session.pk = pk
+	return session
+}
+
+func (session *Session) pkName() string {
+	if len(session.pk) < 1 {
+		return "id"
+	}
+	return session.pk
+}
+
 func (session *Session) Prepare(sqlstr string) *Session {
 
 	session.prepare = sqlstr
@@ -62,21 +168,25 @@ func (session *Session) Prepare(sqlstr string) *Session {
 }
 
 func (session *Session) Query(args ...interface{}) ([]map[string]string, error) {
+	return session.query(OpQuery, "", args...)
+}
+
+//执行 Prepare 的查询语句, op/table 用于拦截器区分语句
+func (session *Session) query(op string, table string, args ...interface{}) ([]map[string]string, error) {
 
 	defer session.clearSession()
 
+	if session.err != nil {
+		return nil, session.err
+	}
+
 	if len(session.prepare) < 1 {
 		return nil, errors.New("请先调用 Prepare方法")
 	}
 
 	session.args = args
 
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(session.prepare)
-	}
-
-	_, allValues,err := session.getRows(session.prepare)
+	_, allValues,err := session.getRows(&Statement{Op: op, Table: table, SQL: session.prepare, Args: session.args})
 	if err != nil {
 		return nil, err
 	}
@@ -89,65 +199,75 @@ func (session *Session) Exec(args ...interface{}) (sql.Result, error) {
 
 	defer session.clearSession()
 
+	if session.err != nil {
+		return nil, session.err
+	}
+
 	if len(session.prepare) < 1 {
 		return nil, errors.New("请先调用 Prepare方法")
 	}
 
 	session.args = args
 
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(session.prepare)
-	}
-
-	var stmtIns *sql.Stmt
-	var err error
-
-	if session.Tx != nil {
-		stmtIns, err = session.Tx.Prepare(session.prepare)
-	} else {
-		stmtIns, err = session.Engine.db.Prepare(session.prepare)
-	}
-
+	ret, err := session.run(&Statement{Op: OpExec, SQL: session.prepare, Args: session.args})
 	if err != nil {
-		log.Printf("prepare error: %s\n", err)
 		return nil, err
 	}
-	defer stmtIns.Close()
-
-	res, err := stmtIns.Exec(session.args...)
 
-	return res, err
+	return ret.Exec, nil
 
 }
 
 func (session *Session) Insert(data map[string]interface{}) (int64, error) {
 
+	lastInsertId, _, err := session.insert(data)
+
+	return lastInsertId, err
+}
+
+//Insert 的实现, 返回自增 id 和影响行数
+func (session *Session) insert(data map[string]interface{}) (int64, int64, error) {
+
 	defer session.clearSession()
 
+	if session.err != nil {
+		return 0, 0, session.err
+	}
+
 	if len(session.TableName) < 1 {
-		return 0, errors.New("没有相应的表明")
+		return 0, 0, errors.New("没有相应的表明")
 	}
 
+	if err := session.checkUpsertDialect(); err != nil {
+		return 0, 0, err
+	}
+
+	//Model 指定的模型有 zycreated/zyupdated 字段时, 自动填充时间
+	data = session.withTimestamps(data, true)
+
 	if len(data) < 1 {
-		return 0, errors.New("参数没有数据")
+		return 0, 0, errors.New("参数没有数据")
 	}
 
 	var args []interface{}
 
 	kstr := "("
 	vstr := "("
-	for k, v := range data {
+	//按字段名排序, 保证相同的参数生成的 sql 完全相同
+	for _, k := range sortedKeys(data) {
+		v := data[k]
+
+		placeholder, valueArgs := valueSql(v)
 
 		if len(kstr) > 1 {
-			kstr += "," + "`" + k + "`"
-			vstr += ",?"
+			kstr += "," + session.quote(k)
+			vstr += "," + placeholder
 		} else {
-			kstr += "`" + k + "`"
-			vstr += "?"
+			kstr += session.quote(k)
+			vstr += placeholder
 		}
 
-		args = append(args, v)
+		args = append(args, valueArgs...)
 
 	}
 
@@ -155,81 +275,195 @@ func (session *Session) Insert(data map[string]interface{}) (int64, error) {
 	kstr += ")"
 	vstr += ")"
 
-	sqlstr := "INSERT " + session.TableName + kstr + " VALUES " + vstr
+	sqlstr := session.insertVerb() + " INTO " + session.TableName + kstr + " VALUES " + vstr
+
+	//ON DUPLICATE KEY UPDATE 子句
+	duplicate, duplicateArgs := session.duplicateClause(sortedKeys(data))
+	sqlstr += duplicate
+	args = append(args, duplicateArgs...)
+
+	//不支持 LastInsertId 的方言, 用 RETURNING 取回主键
+	returning := session.Engine.dialect.ReturningClause(session.pkName())
+	sqlstr += returning
 
 	session.args = args
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
-	}
 
-	var stmtIns *sql.Stmt
-	var err error
+	stmt := &Statement{Op: OpInsert, Table: session.TableName, SQL: sqlstr, Args: args}
 
-	if session.Tx != nil {
-		stmtIns, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtIns, err = session.Engine.db.Prepare(sqlstr)
+	if len(returning) > 0 {
+		var lastInsertId int64
+		stmt.scan = func(rows *sql.Rows) (int64, error) {
+			if !rows.Next() {
+				return 0, sql.ErrNoRows
+			}
+			return 1, rows.Scan(&lastInsertId)
+		}
+
+		_, err := session.run(stmt)
+
+		if err != nil {
+			return 0, 0, err
+		}
+		return lastInsertId, 1, nil
 	}
 
+	ret, err := session.run(stmt)
 
 	if err != nil {
-		 return 0, err
+		return 0, 0, err
 	}
 
-	defer stmtIns.Close()
-
-	ret, err := stmtIns.Exec(args...)
+	lastInsertId, err := ret.Exec.LastInsertId()
 
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	lastInsertId, err := ret.LastInsertId()
+	rowsAffected, err := ret.Exec.RowsAffected()
 
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return lastInsertId, nil
+	return lastInsertId, rowsAffected, nil
 
 }
 
+//批量插入, 返回影响行数
+//数据超过占位符数量或 Engine.MaxAllowedPacket 时自动分成多条语句, 没有在事务中时会放在一个事务中执行
 func (session *Session) InsertAll(datas []map[string]interface{}) (int64, error) {
 
+	ret, err := session.insertAll(datas, false)
+
+	return ret.rowsAffected, err
+}
+
+//批量插入的结果
+type BatchResult struct {
+	RowsAffected int64	//所有语句影响行数的和
+	InsertIds []int64	//每条语句的第一个自增 id (mysql), 不分批时只有一个
+}
+
+//同 InsertAll, 返回每条分批语句的第一个自增 id
+func (session *Session) InsertBatch(datas []map[string]interface{}) (BatchResult, error) {
+
+	ret, err := session.insertAll(datas, false)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	chunkIds := ret.chunkIds
+	if len(chunkIds) < 1 {
+		chunkIds = []int64{ret.lastInsertId}
+	}
+
+	return BatchResult{RowsAffected: ret.rowsAffected, InsertIds: chunkIds}, nil
+}
+
+//批量 insert 的结果
+type insertResult struct {
+	lastInsertId int64	//mysql 中是第一行的自增 id
+	rowsAffected int64
+	ids []int64	//每一行的自增 id, 只有 withIds 为 true 时才有
+	chunkIds []int64	//分批执行时每条语句的 LastInsertId
+}
+
+//InsertAll 的实现, withIds 为 true 时同时返回每一行的自增 id
+func (session *Session) insertAll(datas []map[string]interface{}, withIds bool) (insertResult, error) {
+
 	defer session.clearSession()
 
+	if session.err != nil {
+		return insertResult{}, session.err
+	}
+
 	if len(session.TableName) < 1 {
-		return 0, errors.New("没有相应的表明")
+		return insertResult{}, errors.New("没有相应的表明")
+	}
+
+	if err := session.checkUpsertDialect(); err != nil {
+		return insertResult{}, err
+	}
+
+	if err := session.checkKeyModeDialect(); err != nil {
+		return insertResult{}, err
 	}
 
 	if len(datas) < 1 {
-		return 0, errors.New("参数没有数据")
+		return insertResult{}, errors.New("参数没有数据")
 	}
 
-	var args []interface{}
+	//Model 指定的模型有 zycreated/zyupdated 字段时, 自动填充时间
+	if _, ok := session.modelTable(); ok {
+		filled := make([]map[string]interface{}, len(datas))
+		for i, data := range datas {
+			filled[i] = session.withTimestamps(data, true)
+		}
+		datas = filled
+	}
 
-	kdate := datas[0]
+	keys, err := session.insertKeys(datas)
+	if err != nil {
+		return insertResult{}, err
+	}
 
-	if (len(kdate) * len(datas)) > 65535 {
-		//在一个sql 语句中，最大占位符数量是有限制的，最大值为16bit 无符号数的最大值，即65535。
-		//条数 len(datas) * 每条内容数量 len(kdate) 要小于65535
-		return 0, errors.New("占位符数量超过 65535")
+	//数据太多时分成多条 insert 语句执行
+	chunks, err := session.chunkRows(datas, keys)
+	if err != nil {
+		return insertResult{}, err
 	}
 
-	if len(kdate) < 1 {
-		return 0, errors.New("参数key没有数据")
+	if len(chunks) == 1 {
+		return session.insertChunk(datas, keys, withIds)
 	}
 
-	keys := []string{}
+	var result insertResult
+
+	insertChunks := func(s *Session) error {
+
+		for i, chunk := range chunks {
+
+			ret, err := s.insertChunk(chunk, keys, withIds)
+			if err != nil {
+				return err
+			}
+
+			if i == 0 {
+				result.lastInsertId = ret.lastInsertId
+			}
+			result.rowsAffected += ret.rowsAffected
+			result.ids = append(result.ids, ret.ids...)
+			result.chunkIds = append(result.chunkIds, ret.lastInsertId)
+		}
+
+		return nil
+	}
+
+	//没有在事务中时, 所有分批的语句放在一个事务中执行, DryRun 时不开启事务
+	if session.Tx != nil || session.isDryRun() {
+		err = insertChunks(session)
+	} else {
+		err = session.Transaction(insertChunks)
+	}
+
+	if err != nil {
+		return insertResult{}, err
+	}
+
+	return result, nil
+}
+
+//执行一条批量 insert 语句, keys 为插入的字段
+func (session *Session) insertChunk(datas []map[string]interface{}, keys []string, withIds bool) (insertResult, error) {
+
+	var args []interface{}
 
 	kstr := "("
-	for k, _ := range kdate {
-		keys = append(keys, k)
+	for _, k := range keys {
 
 		if len(kstr) > 1 {
-			kstr += "," + "`" + k + "`"
+			kstr += "," + session.quote(k)
 		} else {
-			kstr += "`" + k + "`"
+			kstr += session.quote(k)
 		}
 	}
 	kstr += ")"
@@ -247,13 +481,21 @@ func (session *Session) InsertAll(datas []map[string]interface{}) (int64, error)
 
 		for i, key := range keys {
 
-			if i == 0 {
-				vstr += "?"
-			} else {
-				vstr += ",?"
+			if i > 0 {
+				vstr += ","
+			}
+
+			value, ok := data[key]
+
+			//InsertKeysUnion 模式下, 这一行没有的字段使用默认值
+			if !ok && session.insertKeyMode() == InsertKeysUnion {
+				vstr += "DEFAULT"
+				continue
 			}
 
-			args = append(args, data[key])
+			placeholder, valueArgs := valueSql(value)
+			vstr += placeholder
+			args = append(args, valueArgs...)
 		}
 
 		vstr += ")"
@@ -263,42 +505,81 @@ func (session *Session) InsertAll(datas []map[string]interface{}) (int64, error)
 
 
 
-	sqlstr := "INSERT " + session.TableName + kstr + " VALUES " + values
+	sqlstr := session.insertVerb() + " INTO " + session.TableName + kstr + " VALUES " + values
 
-	session.args = args
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
+	//ON DUPLICATE KEY UPDATE 子句
+	duplicate, duplicateArgs := session.duplicateClause(keys)
+	sqlstr += duplicate
+	args = append(args, duplicateArgs...)
+
+	returning := ""
+	if withIds {
+		returning = session.Engine.dialect.ReturningClause(session.pkName())
+		sqlstr += returning
 	}
 
-	var stmtIns *sql.Stmt
-	var err error
+	session.args = args
 
-	if session.Tx != nil {
-		stmtIns, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtIns, err = session.Engine.db.Prepare(sqlstr)
+	stmt := &Statement{Op: OpInsert, Table: session.TableName, SQL: sqlstr, Args: args}
+
+	if len(returning) > 0 {
+		var ids []int64
+		stmt.scan = func(rows *sql.Rows) (int64, error) {
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					return int64(len(ids)), err
+				}
+				ids = append(ids, id)
+			}
+			return int64(len(ids)), nil
+		}
+
+		if _, err := session.run(stmt); err != nil {
+			return insertResult{}, err
+		}
+
+		return insertResult{rowsAffected: int64(len(ids)), ids: ids}, nil
 	}
 
+	ret, err := session.run(stmt)
 
 	if err != nil {
-		return 0, err
+		return insertResult{}, err
 	}
 
-	defer stmtIns.Close()
+	//DryRun 时没有真正插入, 没有 id 可以写回
+	if session.isDryRun() {
+		return insertResult{}, nil
+	}
 
-	ret, err := stmtIns.Exec(args...)
+	rowsAffected, err := ret.Exec.RowsAffected()
 
 	if err != nil {
-		return 0, err
+		return insertResult{}, err
 	}
 
-	rowsAffected, err := ret.RowsAffected()
+	//不需要 id 时, 不支持 LastInsertId 的驱动也不报错
+	lastInsertId, err := ret.Exec.LastInsertId()
+
+	if !withIds {
+		return insertResult{lastInsertId: lastInsertId, rowsAffected: rowsAffected}, nil
+	}
 
 	if err != nil {
-		return 0, err
+		return insertResult{}, err
+	}
+
+	//影响行数和插入的行数不同时, 有的行没有插入, 不能按连续的自增 id 推算
+	if rowsAffected != int64(len(datas)) {
+		return insertResult{lastInsertId: lastInsertId, rowsAffected: rowsAffected}, nil
 	}
-	return rowsAffected, nil
+
+	return insertResult{
+		lastInsertId: lastInsertId,
+		rowsAffected: rowsAffected,
+		ids: insertIds(session.Engine.dialect, lastInsertId, len(datas)),
+	}, nil
 
 }
 
@@ -306,25 +587,54 @@ func (session *Session) Update(data map[string]interface{}) (int64, error) {
 
 	defer session.clearSession()
 
+	if session.err != nil {
+		return 0, session.err
+	}
+
 	if len(session.TableName) < 1 {
 		return 0, errors.New("没有相应的表明")
 	}
 
+	//Model 指定的模型有 zyupdated 字段时, 自动更新时间
+	data = session.withTimestamps(data, false)
+
+	//Incr/Decr 设置的字段和 data 合并, 同一个字段以 Incr/Decr 为准
+	if len(session.incrs) > 0 {
+		merged := make(map[string]interface{}, len(data)+len(session.incrs))
+		for k, v := range data {
+			merged[k] = v
+		}
+		for k, v := range session.incrs {
+			merged[k] = v
+		}
+		data = merged
+	}
+
 	if len(data) < 1 {
 		return 0, errors.New("参数没有数据")
 	}
 
+	//和 delete 一样, 默认不允许不带 where 更新整个表
+	if len(session.where) < 1 && !session.allowFullTable {
+		return 0, errors.New("update 必须设置 where, 更新整个表请调用 AllowFullTable 或 UpdateAll")
+	}
+
+	if err := session.checkAlwaysTrue(); err != nil {
+		return 0, err
+	}
+
 	var args []interface{}
 
 	setStr := ""
-	for k, v := range data {
+	for _, k := range sortedKeys(data) {
+		placeholder, valueArgs := valueSql(data[k])
 
 		if len(setStr) > 0 {
-			setStr += "," + "`" + k + "`" + "=?"
+			setStr += "," + session.quote(k) + "=" + placeholder
 		} else {
-			setStr += "`" + k + "`" + "=?"
+			setStr += session.quote(k) + "=" + placeholder
 		}
-		args = append(args, v)
+		args = append(args, valueArgs...)
 	}
 
 	sqlstr := "UPDATE " + session.TableName + " SET " + setStr  //kstr + " VALUES " + vstr
@@ -335,36 +645,14 @@ func (session *Session) Update(data map[string]interface{}) (int64, error) {
 	}
 
 	session.args = args
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
-	}
 
-	var stmtIns *sql.Stmt
-	var err error
-
-
-	if session.Tx != nil {
-		stmtIns, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtIns, err = session.Engine.db.Prepare(sqlstr)
-	}
-
-
-
-	if err != nil {
-		return 0, err
-	}
-
-	defer stmtIns.Close()
-
-	ret, err := stmtIns.Exec(args...)
+	ret, err := session.run(&Statement{Op: OpUpdate, Table: session.TableName, SQL: sqlstr, Args: args})
 
 	if err != nil {
 		return 0, err
 	}
 
-	rowsAffected, err := ret.RowsAffected()
+	rowsAffected, err := ret.Exec.RowsAffected()
 
 	if err != nil {
 		return 0, err
@@ -378,6 +666,10 @@ func (session *Session) Delete() (int64, error) {
 
 	defer session.clearSession()
 
+	if session.err != nil {
+		return 0, session.err
+	}
+
 	if len(session.TableName) < 1 {
 		return 0, errors.New("没有相应的表明")
 	}
@@ -386,39 +678,44 @@ func (session *Session) Delete() (int64, error) {
 		return 0, errors.New("delete 必须设置 where")
 	}
 
-	session.args = append(session.args, session.whereArgs...)
-	sqlstr := "DELETE FROM " + session.TableName + " WHERE " + session.where
+	if err := session.checkAlwaysTrue(); err != nil {
+		return 0, err
+	}
+
+	//clearSession 会清空 model, 先保存下来给 AfterDelete 使用
+	model := session.model
 
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
+	if err := session.beforeDelete(model); err != nil {
+		return 0, err
 	}
 
-	var stmtIns *sql.Stmt
-	var err error
+	var sqlstr string
 
-	if session.Tx != nil {
-		stmtIns, err = session.Tx.Prepare(sqlstr)
+	//Model 指定的模型有 zydeleted 字段时, 只更新删除时间
+	if col := session.softDeleteColumn(); len(col) > 0 {
+		session.args = append(session.args, session.Engine.now())
+		session.args = append(session.args, session.whereArgs...)
+		sqlstr = "UPDATE " + session.TableName + " SET " + session.quote(col) + "=? WHERE (" + session.where + ") and " + session.quote(col) + " IS NULL"
 	} else {
-		stmtIns, err = session.Engine.db.Prepare(sqlstr)
+		session.args = append(session.args, session.whereArgs...)
+		sqlstr = "DELETE FROM " + session.TableName + " WHERE " + session.where
 	}
 
+	//软删除执行的是 update, Op 仍然是 OpDelete
+	ret, err := session.run(&Statement{Op: OpDelete, Table: session.TableName, SQL: sqlstr, Args: session.args})
+
 	if err != nil {
 		return 0, err
 	}
 
-	defer stmtIns.Close()
-
-	ret, err := stmtIns.Exec(session.args...)
+	rowsAffected, err := ret.Exec.RowsAffected()
 
 	if err != nil {
 		return 0, err
 	}
 
-	rowsAffected, err := ret.RowsAffected()
-
-	if err != nil {
-		return 0, err
+	if err = session.afterDelete(model); err != nil {
+		return rowsAffected, err
 	}
 
 	return rowsAffected, nil
@@ -432,60 +729,39 @@ func (session *Session) Find(p interface{}) (bool, error) {
 
 	defer session.clearSession()
 
-
-	t, _, realV, err := session.getReflects(p)
-
-	if err != nil {
-		return false, err
+	if session.err != nil {
+		return false, session.err
 	}
 
 
-	sqlstr, err := session.getSqlStr(t)
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
-	}
+	t, _, realV, err := session.getReflects(p)
 
 	if err != nil {
 		return false, err
 	}
 
-	//根据 sql 查数据
-	var stmtOut *sql.Stmt
-	if session.Tx != nil {
-		stmtOut, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtOut, err = session.Engine.db.Prepare(sqlstr)
-	}
 
-	if err != nil {
-		log.Printf("prepare error: %s\n", err)
-		return false, err
-	}
-	defer stmtOut.Close()
+	sqlstr, err := session.getSqlStr(t)
 
-	rows, err := stmtOut.Query(session.args...)
 	if err != nil {
-		log.Printf("Query error: %s\n", err)
 		return false, err
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("rows Err: %s\n", err)
-		return false, err
-	}
-	defer rows.Close()
+	var columns []string
+	var values []sql.RawBytes
 
+	//根据 sql 查数据, 只查一条
+	_, err = session.run(&Statement{Op: OpSelect, Table: session.selectTable(t), SQL: sqlstr, Args: session.args, scan: func(rows *sql.Rows) (int64, error) {
 
-	columns, err := rows.Columns()
-	if err != nil {
-		log.Printf("get Columns error: %s\n", err)
-		return false, err
-	}
+		columns, err = rows.Columns()
+		if err != nil {
+			return 0, err
+		}
 
-	var values []sql.RawBytes
+		if !rows.Next() {
+			return 0, nil
+		}
 
-	for rows.Next() {
 		//切片是地址, 所以每次都重新创建 values, scanArgs
 		values = make([]sql.RawBytes, len(columns))
 
@@ -496,12 +772,21 @@ func (session *Session) Find(p interface{}) (bool, error) {
 
 		err = rows.Scan(scanArgs...)
 		if err != nil {
-			log.Printf("get Scan error: %s\n", err)
-			return false, err
+			return 0, err
 		}
 
-		//只查一条, 所以循环一次退出
-		break
+		//RawBytes 在 rows 关闭后失效, 这里复制一份
+		for i := range values {
+			if values[i] != nil {
+				values[i] = append(sql.RawBytes{}, values[i]...)
+			}
+		}
+
+		return 1, nil
+	}})
+
+	if err != nil {
+		return false, err
 	}
 
 	if len(values) < 1 {
@@ -510,6 +795,10 @@ func (session *Session) Find(p interface{}) (bool, error) {
 
 	session.setValues(columns, values, t, realV)
 
+	if err = session.afterFind(realV); err != nil {
+		return false, err
+	}
+
 	return true, nil
 
 }
@@ -518,89 +807,68 @@ func (session *Session) Select(p interface{}) error {
 
 	defer session.clearSession()
 
-	t, v, realV, err := session.getReflects(p)
-
-
-	if err != nil {
-		return err
-	}
-
-	sqlstr, err := session.getSqlStr(t)
-
-	//根据设置输出 sql
-	if session.Engine.ShowSql {
-		session.printSql(sqlstr)
-	}
-
-	if err != nil {
-		return err
+	if session.err != nil {
+		return session.err
 	}
 
-	var stmtOut *sql.Stmt
+	t, v, realV, err := session.getReflects(p)
 
-	if session.Tx != nil {
-		stmtOut, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtOut, err = session.Engine.db.Prepare(sqlstr)
-	}
 
 	if err != nil {
-		log.Printf("prepare error: %s\n", err)
 		return err
 	}
 
-	defer stmtOut.Close()
+	sqlstr, err := session.getSqlStr(t)
 
-	rows, err := stmtOut.Query(session.args...)
 	if err != nil {
-		log.Printf("Query error: %s\n", err)
 		return err
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("rows Err: %s\n", err)
-		return err
-	}
+	elements := make([]reflect.Value, 0)
 
-	defer rows.Close()
+	_, err = session.run(&Statement{Op: OpSelect, Table: session.selectTable(t), SQL: sqlstr, Args: session.args, scan: func(rows *sql.Rows) (int64, error) {
 
+		columns, err := rows.Columns()
+		if err != nil {
+			return 0, err
+		}
 
-	columns, err := rows.Columns()
-	if err != nil {
-		log.Printf("get Columns error: %s\n", err)
-		return err
-	}
+		//循环输出 mysql 返回数据
+		for rows.Next() {
 
+			//切片是地址, 所以每次都重新创建 values, scanArgs
+			values := make([]sql.RawBytes, len(columns))
 
-	elements := make([]reflect.Value, 0)
-
-	//判断是否有值, 如果没有, 根据
-	hasValue := false
+			scanArgs := make([]interface{}, len(values))
+			for i := range values {
+				scanArgs[i] = &values[i]
+			}
 
-	//循环输出 mysql 返回数据
-	for rows.Next() {
+			err = rows.Scan(scanArgs...)
+			if err != nil {
+				return int64(len(elements)), err
+			}
 
-		hasValue = true
+			session.setValues(columns, values, t, v)
 
-		//切片是地址, 所以每次都重新创建 values, scanArgs
-		values := make([]sql.RawBytes, len(columns))
+			if err = session.afterFind(v); err != nil {
+				return int64(len(elements)), err
+			}
 
-		scanArgs := make([]interface{}, len(values))
-		for i := range values {
-			scanArgs[i] = &values[i]
-		}
+			elements = append(elements, reflect.ValueOf(v.Interface()))
 
-		err = rows.Scan(scanArgs...)
-		if err != nil {
-			log.Printf("get Scan error: %s\n", err)
-			return err
 		}
 
-		session.setValues(columns, values, t, v)
-		elements = append(elements, reflect.ValueOf(v.Interface()))
+		return int64(len(elements)), nil
+	}})
 
+	if err != nil {
+		return err
 	}
 
+	//判断是否有值, 如果没有, 根据 SelectNilSlice2EmptySlice 处理
+	hasValue := len(elements) > 0
+
 	if !hasValue && session.Engine.SelectNilSlice2EmptySlice {
 
 		//如果没有数据, 并且设置 SelectNilSlice2EmptySlice 为 true, 这里赋值空数组
@@ -618,18 +886,27 @@ func (session *Session) Count() (int64, error) {
 
 	s := "SELECT COUNT(*) c FROM " + session.TableName
 
-	if len(session.where) > 0 {
-		s += " WHERE " + session.where
+	//Model 指定的模型有 zydeleted 字段时, 不统计已删除的数据
+	where := session.where
+	if tableInfo, ok := session.modelTable(); ok {
+		where = session.withSoftDeleteWhere(where, tableInfo, session.TableName)
+	}
+
+	if len(where) > 0 {
+		s += " WHERE " + where
 		session.args = append(session.args, session.whereArgs...)
 	}
 
-	m, err := session.Prepare(s).Query(session.args...)
+	m, err := session.Prepare(s).query(OpCount, session.TableName, session.args...)
 
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	if len(m) < 1 {
+		if session.isDryRun() {
+			return 0, nil
+		}
 		return 0, errors.New("获取数量失败")
 	}
 
@@ -644,45 +921,55 @@ func (session *Session) Fields(fields string) *Session {
 	return session
 }
 
-func (session *Session) Where(wheres map[string]interface{}) *Session {
-
-	//如果有内容添加 ()
-	if len(wheres) > 0 {
-
-		if len(session.where) > 0 {
-			session.where += " and ("
-		} else {
-			session.where += " ("
-		}
+//添加 and 条件, wheres 可以是 map[string]interface{} 或 And/Or/Not/Eq 等组合出来的 Cond
+func (session *Session) Where(wheres interface{}) *Session {
+	return session.addWhere(" and (", wheres)
+}
 
-		session.manageWhere(wheres)
+//添加 or 条件, wheres 同 Where
+func (session *Session) OrWhere(wheres interface{}) *Session {
+	return session.addWhere(" or (", wheres)
+}
 
-		session.where += ")"
+func (session *Session) addWhere(join string, wheres interface{}) *Session {
 
+	cond, err := toCond(wheres)
+	if err != nil {
+		session.setErr(err)
+		return session
 	}
 
-	return session
-}
+	where, args, err := cond.build(session)
+	if err != nil {
+		session.setErr(err)
+		return session
+	}
 
-func (session *Session) OrWhere(wheres map[string]interface{}) *Session {
 	//如果有内容添加 ()
-	if len(wheres) > 0 {
+	if len(where) > 0 {
 
 		if len(session.where) > 0 {
-			session.where += " or ("
+			session.where += join
 		} else {
 			session.where += " ("
 		}
 
-		session.manageWhere(wheres)
-
-		session.where += ")"
+		session.where += where + ")"
+		session.whereArgs = append(session.whereArgs, args...)
 
+		session.trackAlwaysTrue(join == " or (", condAlwaysTrue(cond))
 	}
 
 	return session
 }
 
+//记录链式调用中出现的错误, 在真正执行时返回, 只保留第一个
+func (session *Session) setErr(err error) {
+	if session.err == nil {
+		session.err = err
+	}
+}
+
 func (session *Session) Limit(args ...interface{}) *Session {
 
 	switch len(args) {
@@ -749,270 +1036,197 @@ func (session *Session) Join(join string, args ...interface{}) *Session {
 	return session
 }
 
-func (session *Session) manageWhere(wheres map[string]interface{}) {
+//把 map 形式的条件转成 sql, 多个条件之间用 and 连接
+//value 的写法:
+//	"a": 1                                   `a` =?
+//	"a": nil                                 `a` IS NULL
+//	"a": []interface{}{">", 1}               =, >, >=, <, <=, <>, !=, LIKE, NOT LIKE, REGEXP, NOT REGEXP
+//	"a": []interface{}{"IN", []int{1, 2}}    IN, NOT IN, 参数可以是切片、逗号分隔的字符串或单个值
+//	"a": []interface{}{"BETWEEN", 1, 2}      BETWEEN, NOT BETWEEN, 也可以写成 {"BETWEEN", []int{1, 2}}
+//	"a": []interface{}{"IS NOT NULL"}        IS NULL, IS NOT NULL
+//不支持的操作符或参数类型返回错误, 不会生成不完整的 sql
+func (session *Session) manageWhere(wheres map[string]interface{}) (string, []interface{}, error) {
+
+	var where string
+	var whereArgs []interface{}
 
 	isFirst := true
 
-	for k, v := range wheres {
+	for _, k := range sortedKeys(wheres) {
+		v := wheres[k]
+
+		var field string
 
 		index := strings.Index(k, ".")
 
 		if index > 0 {
-
 			table := k[:index]
-			field := k[index+1:]
-
-			if isFirst {
-				isFirst = false
-				session.where += " " + table + ".`" + field + "` "
-			} else {
-				session.where += " and " + table + ".`" + field + "` "
-			}
-
+			field = table + "." + session.quote(k[index+1:])
 		} else {
-			if isFirst {
-				isFirst = false
-				session.where += " `" + k + "` "
-			} else {
-				session.where += " and `" + k + "` "
-			}
+			field = session.quote(k)
 		}
 
+		cond, args, err := whereValue(v)
+		if err != nil {
+			return "", nil, errors.New("zyorm: where 条件 " + k + " " + err.Error())
+		}
 
+		if isFirst {
+			isFirst = false
+			where += " " + field + " " + cond
+		} else {
+			where += " and " + field + " " + cond
+		}
 
+		whereArgs = append(whereArgs, args...)
+	}
 
+	return where, whereArgs, nil
+}
 
-		switch v.(type) {
-		case
-			string,
-			int,
-			int8,
-			int16,
-			int32,
-			int64,
-			uint,
-			uint8,
-			uint16,
-			uint32,
-			uint64,
-			float32,
-			float64:
-
-				session.where += "=?"
-
-				session.whereArgs = append(session.whereArgs, v)
-
-		case []interface{}:
-
-			var t string
-			if len(v.([]interface{})) > 1 {
-
-				t = v.([]interface{})[0].(string)
-
-				t = strings.ToUpper(t)
-
-				v1 := v.([]interface{})[1]
-				switch t {
-				case "=", ">", ">=", "<", "<=", "<>", "!=", "LIKE":
-
-					session.where += t + " ? "
-
-
-					session.whereArgs = append(session.whereArgs, v1)
-
-
-				case "IN":
-
-					switch v1.(type) {
-					case string:
-						v1s := strings.Split(v1.(string), ",")
-						session.where += t + " ( "
-
-						for i, stringv := range v1s {
-
-							if i == 0 {
-								session.where += " ? "
-							} else {
-								session.where += " ,? "
-							}
-
-							session.whereArgs = append(session.whereArgs, stringv)
-						}
-						session.where += " ) "
-
-					case
-
-						int,
-						int8,
-						int16,
-						int32,
-						int64,
-						uint,
-						uint8,
-						uint16,
-						uint32,
-						uint64,
-						float32,
-						float64:
-							session.where += t + " (?) "
-							session.whereArgs = append(session.whereArgs, v1)
-					case []int:
-						session.where += t + " ("
-
-						for i, intv := range v1.([]int) {
-
-							if i == 0 {
-								session.where += " ? "
-							} else {
-								session.where += " ,? "
-							}
-
-							session.whereArgs = append(session.whereArgs, intv)
-						}
-						session.where += " ) "
-					case []string:
-						session.where += t + " ( "
-
-						for i, intv := range v1.([]string) {
-
-							if i == 0 {
-								session.where += " ? "
-							} else {
-								session.where += " ,? "
-							}
-
-							session.whereArgs = append(session.whereArgs, intv)
-						}
-						session.where += " ) "
-					case []float64:
-						session.where += t + " ( "
-
-						for i, intv := range v1.([]float64) {
-
-							if i == 0 {
-								session.where += " ? "
-							} else {
-								session.where += " ,? "
-							}
-
-							session.whereArgs = append(session.whereArgs, intv)
-						}
-						session.where += " ) "
-					case []interface{}:
-						session.where += t + " ( "
-
-						for i, intv := range v1.([]interface{}) {
-
-							if i == 0 {
-								session.where += " ? "
-							} else {
-								session.where += " ,? "
-							}
-
-							session.whereArgs = append(session.whereArgs, intv)
-						}
-						session.where += " ) "
-					}
-
-				case "BETWEEN":
+//生成单个条件字段名后面的部分和参数
+func whereValue(v interface{}) (string, []interface{}, error) {
 
-					if len(v.([]interface{})) == 3 {
-						v2 := v.([]interface{})[1]
-						v3 := v.([]interface{})[2]
+	if v == nil {
+		return "IS NULL", nil, nil
+	}
 
-						session.where += t + " ? and ? "
-						session.whereArgs = append(session.whereArgs, v2, v3)
+	if isScalar(v) {
+		return "=?", []interface{}{v}, nil
+	}
 
-					} else if len(v.([]interface{})) < 3 {
+	vs, ok := v.([]interface{})
+	if !ok {
+		return "", nil, errors.New("不支持的参数类型 " + reflect.TypeOf(v).String())
+	}
 
-						switch v1.(type) {
+	if len(vs) < 1 {
+		return "", nil, errors.New("参数为空")
+	}
 
-						case []int:
+	t, ok := vs[0].(string)
+	if !ok {
+		return "", nil, errors.New("第一个元素需要是字符串类型的操作符")
+	}
 
-							if len(v1.([]int)) == 2 {
-								session.where += t
+	t = strings.ToUpper(strings.Join(strings.Fields(t), " "))
 
-								for i, intv := range v1.([]int) {
+	switch t {
+	case "IS NULL", "IS NOT NULL":
+		return t, nil, nil
+	}
 
-									if i == 0 {
-										session.where += " ? "
-									} else {
-										session.where += " and ? "
-									}
+	if len(vs) < 2 {
+		return "", nil, errors.New(t + " 缺少参数")
+	}
 
-									session.whereArgs = append(session.whereArgs, intv)
-								}
-							}
-						case []float64:
+	v1 := vs[1]
 
-							if len(v1.([]float64)) == 2 {
-								session.where += t
+	switch t {
+	case "=", ">", ">=", "<", "<=", "<>", "!=", "LIKE", "NOT LIKE", "REGEXP", "NOT REGEXP":
 
-								for i, intv := range v1.([]float64) {
+		if v1 == nil {
+			switch t {
+			case "=":
+				return "IS NULL", nil, nil
+			case "<>", "!=":
+				return "IS NOT NULL", nil, nil
+			}
+			return "", nil, errors.New(t + " 的参数不能为 nil")
+		}
 
-									if i == 0 {
-										session.where += " ? "
-									} else {
-										session.where += " and ? "
-									}
+		if !isScalar(v1) {
+			return "", nil, errors.New(t + " 不支持的参数类型 " + reflect.TypeOf(v1).String())
+		}
 
-									session.whereArgs = append(session.whereArgs, intv)
-								}
-							}
+		return t + " ? ", []interface{}{v1}, nil
 
-						case []string:
+	case "IN", "NOT IN":
 
-							if len(v1.([]string)) == 2 {
-								session.where += t
+		var list []interface{}
 
-								for i, intv := range v1.([]string) {
+		if s, ok := v1.(string); ok {
+			for _, item := range strings.Split(s, ",") {
+				list = append(list, item)
+			}
+		} else if isScalar(v1) {
+			list = []interface{}{v1}
+		} else {
+			var err error
+			list, err = sliceArgs(v1)
+			if err != nil {
+				return "", nil, errors.New(t + " " + err.Error())
+			}
+		}
 
-									if i == 0 {
-										session.where += " ? "
-									} else {
-										session.where += " and ? "
-									}
+		if len(list) < 1 {
+			return "", nil, errors.New(t + " 的参数不能为空")
+		}
 
-									session.whereArgs = append(session.whereArgs, intv)
-								}
-							}
-						case []interface{}:
+		return t + " (" + strings.TrimSuffix(strings.Repeat("?,", len(list)), ",") + ") ", list, nil
 
-							if len(v1.([]interface{})) == 2 {
-								session.where += t
+	case "BETWEEN", "NOT BETWEEN":
 
-								for i, intv := range v1.([]interface{}) {
+		var list []interface{}
 
-									if i == 0 {
-										session.where += " ? "
-									} else {
-										session.where += " and ? "
-									}
+		if len(vs) == 3 {
+			list = vs[1:]
+		} else if len(vs) == 2 {
+			var err error
+			list, err = sliceArgs(v1)
+			if err != nil {
+				return "", nil, errors.New(t + " " + err.Error())
+			}
+		}
 
-									session.whereArgs = append(session.whereArgs, intv)
+		if len(list) != 2 {
+			return "", nil, errors.New(t + " 需要 2 个参数")
+		}
 
-								}
-							}
+		for _, item := range list {
+			if !isScalar(item) {
+				return "", nil, errors.New(t + " 不支持的参数类型")
+			}
+		}
 
-						}
+		return t + " ? and ? ", list, nil
+	}
 
-					} else {
+	return "", nil, errors.New("不支持的操作符 " + t)
+}
 
+//是否是可以直接作为 sql 参数的单个值, 和 database/sql 的规则一样
+//自定义的整数/字符串类型、指针和实现了 driver.Valuer 的类型都可以, []byte 之外的切片不行
+func isScalar(v interface{}) bool {
 
-					}
+	//mysql 驱动支持最高位为 1 的 uint64, 默认的转换不支持
+	if _, ok := v.(uint64); ok {
+		return true
+	}
 
-				}
+	_, err := driver.DefaultParameterConverter.ConvertValue(v)
 
-			} else {
+	return err == nil
+}
 
-			}
+//把任意类型的切片转成 []interface{}, 元素需要是单个值
+func sliceArgs(v interface{}) ([]interface{}, error) {
 
-		default:
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, errors.New("参数需要是切片")
+	}
 
+	list := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i).Interface()
+		if !isScalar(item) {
+			return nil, errors.New("不支持的参数类型 " + reflect.TypeOf(item).String())
 		}
-
+		list = append(list, item)
 	}
 
+	return list, nil
 }
 
 func (session *Session) setValues(columns []string, values []sql.RawBytes, t reflect.Type, v reflect.Value)  {
@@ -1028,7 +1242,7 @@ func (session *Session) setValues(columns []string, values []sql.RawBytes, t ref
 		f := v.FieldByName(fieldInfo.AttrName)
 
 		//能处理的数据结构提示
-		alertLog := "zyorm 中没有处理 model 中数据类型, 暂时只可以处理(string/int/int8-64/uint/uint8-64/float32-64/bool/time.Time)"
+		alertLog := "zyorm 中没有处理 model 中数据类型, 暂时只可以处理(string/int/int8-64/uint/uint8-64/float32-64/bool/time.Time/*time.Time)"
 
 		if valueBytes != nil {
 			value := string(valueBytes)
@@ -1082,19 +1296,31 @@ func (session *Session) setValues(columns []string, values []sql.RawBytes, t ref
 				}
 			case reflect.Struct:
 				if f.Type().String() == "time.Time" {
-					t, e := time.Parse("2006-01-02 15:04:05", value)
+					t, e := session.Engine.parseTime(value)
 
-					if e == nil {
+					if e != nil {
 						f.Set(reflect.ValueOf(time.Unix(0,0)))
 					} else {
 						f.Set(reflect.ValueOf(t))
 					}
 				} else {
-					log.Println(alertLog)
+					session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
+				}
+			case reflect.Ptr:
+				if f.Type().Elem() == timeType {
+					t, e := session.Engine.parseTime(value)
+
+					if e != nil {
+						f.Set(reflect.Zero(f.Type()))
+					} else {
+						f.Set(reflect.ValueOf(&t))
+					}
+				} else {
+					session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
 				}
 
 			default:
-				log.Println(alertLog)
+				session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
 			}
 		} else {
 			switch f.Kind() {
@@ -1124,10 +1350,17 @@ func (session *Session) setValues(columns []string, values []sql.RawBytes, t ref
 				if f.Type().String() == "time.Time" {
 					f.Set(reflect.ValueOf(time.Unix(0,0)))
 				} else {
-					log.Println(alertLog)
+					session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
+				}
+			case reflect.Ptr:
+				//NULL 的 *time.Time 设置成 nil
+				if f.Type().Elem() == timeType {
+					f.Set(reflect.Zero(f.Type()))
+				} else {
+					session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
 				}
 			default:
-				log.Println(alertLog)
+				session.log(LevelWarn, alertLog, Field{"field", f.Type().String()})
 
 			}
 		}
@@ -1169,16 +1402,9 @@ func (session *Session) getReflects(p interface{}) (reflect.Type, reflect.Value,
 }
 
 func (session *Session) getSqlStr(t reflect.Type) (string, error) {
-	tableInfo, ok := session.Engine.tables[t.Name()]
-
-	if !ok {
-
-		err := session.Engine.registerTable(t)
-		if err != nil {
-			return "", err
-		}
-
-		tableInfo = session.Engine.tables[t.Name()]
+	tableInfo, err := session.Engine.getTable(t)
+	if err != nil {
+		return "", err
 	}
 
 
@@ -1190,9 +1416,10 @@ func (session *Session) getSqlStr(t reflect.Type) (string, error) {
 		fieldStr = session.fields
 	} else {
 
-		tableFields := tableInfo.Fields
+		//按结构体中定义的顺序生成字段
 		var fields []string
-		for _, v := range tableFields {
+		for _, asName := range tableInfo.FieldOrder {
+			v := tableInfo.Fields[asName]
 
 			field := ""
 
@@ -1202,12 +1429,12 @@ func (session *Session) getSqlStr(t reflect.Type) (string, error) {
 
 
 
-			field += "`"+v.FieldName+"`"
+			field += session.quote(v.FieldName)
 
 
 
 			if v.AsName != "" {
-				field += " `" + v.AsName + "`"
+				field += " " + session.quote(v.AsName)
 			}
 
 
@@ -1229,94 +1456,110 @@ func (session *Session) getSqlStr(t reflect.Type) (string, error) {
 	session.args = append(session.args, session.joinArgs...)
 
 
-	if len(session.where) > 0 {
-		sqlstr += " WHERE " + session.where
+	//有 zydeleted 字段时, 只查没有删除的数据
+	where := session.withSoftDeleteWhere(session.where, tableInfo, tableInfo.Name)
+
+	if len(where) > 0 {
+		sqlstr += " WHERE " + where
 		session.args = append(session.args, session.whereArgs...)
 	}
 
+	if len(session.group) > 0 {
+		sqlstr += " GROUP BY " + session.group
+	}
+
 	if len(session.order) > 0 {
 		sqlstr += " ORDER BY " + session.order
 	}
 
 	if len(session.limit) > 0 {
-		sqlstr += " LIMIT " + session.limit
-	}
-
-	if len(session.group) > 0 {
-		sqlstr += " GROUP BY " + session.group
+		sqlstr += " " + session.Engine.dialect.LimitClause(session.limit)
 	}
 
 
 	return sqlstr, nil
 }
 
-func (session *Session) getRows(sqlstr string) ([]string, *[]map[string]string, error) {
+func (session *Session) getRows(stmt *Statement) ([]string, *[]map[string]string, error) {
 
+	var columns []string
+	var allValues = []map[string]string{}
 
-	var stmtOut *sql.Stmt
-	var err error
+	stmt.scan = func(rows *sql.Rows) (int64, error) {
 
-	if session.Tx != nil {
-		stmtOut, err = session.Tx.Prepare(sqlstr)
-	} else {
-		stmtOut, err = session.Engine.db.Prepare(sqlstr)
-	}
+		var err error
+		columns, err = rows.Columns()
+		if err != nil {
+			return 0, err
+		}
 
+		//循环输出 mysql 返回数据
+		for rows.Next() {
 
-	if err != nil {
-		log.Printf("prepare error: %s\n", err)
-		return nil, nil, err
-	}
-	defer stmtOut.Close()
+			//切片是地址, 所以每次都重新创建 values, scanArgs
+			values := make([]sql.RawBytes, len(columns))
 
-	rows, err := stmtOut.Query(session.args...)
-	if err != nil {
-		log.Printf("Query error: %s\n", err)
-		return nil, nil, err
-	}
+			scanArgs := make([]interface{}, len(values))
+			for i := range values {
+				scanArgs[i] = &values[i]
+			}
 
-	if err = rows.Err(); err != nil {
-		log.Printf("rows Err: %s\n", err)
-		return nil, nil, err
+			err = rows.Scan(scanArgs...)
+			if err != nil {
+				return int64(len(allValues)), err
+			}
+
+			m := map[string]string{}
+			for i, v := range values {
+				m[columns[i]] = string(v)
+			}
+			allValues = append(allValues, m)
+
+		}
+
+		return int64(len(allValues)), nil
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
-	if err != nil {
-		log.Printf("get Columns error: %s\n", err)
+	if _, err := session.run(stmt); err != nil {
 		return nil, nil, err
 	}
 
+	return columns, &allValues, nil
+}
 
+//根据是否在事务中, 用 Tx 或 db 预处理 sql
+func (session *Session) prepareStmt(ctx context.Context, sqlstr string) (*sql.Stmt, error) {
 
-	var allValues = []map[string]string{}
+	var stmt *sql.Stmt
+	var err error
 
-	//循环输出 mysql 返回数据
-	for rows.Next() {
+	//生成 sql 时统一使用 ?, 这里按方言转换占位符
+	sqlstr = rebind(session.Engine.dialect, sqlstr)
 
-		//切片是地址, 所以每次都重新创建 values, scanArgs
-		values := make([]sql.RawBytes, len(columns))
+	if session.Tx != nil {
+		stmt, err = session.Tx.PrepareContext(ctx, sqlstr)
+	} else {
+		stmt, err = session.Engine.db.PrepareContext(ctx, sqlstr)
+	}
 
-		scanArgs := make([]interface{}, len(values))
-		for i := range values {
-			scanArgs[i] = &values[i]
-		}
+	return stmt, err
+}
 
-		err = rows.Scan(scanArgs...)
-		if err != nil {
-			log.Printf("get Scan error: %s\n", err)
-			return nil, nil, err
-		}
+//map 的 key 排序后返回, 用于生成顺序固定的 sql
+func sortedKeys(m map[string]interface{}) []string {
 
-		m := map[string]string{}
-		for i, v := range values {
-			m[columns[i]] = string(v)
-		}
-		allValues = append(allValues, m)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
+	return keys
+}
 
-	}
-	return columns, &allValues, nil
+//按方言给表名/字段名加引号
+func (session *Session) quote(name string) string {
+	return session.Engine.dialect.Quote(name)
 }
 
 //TODO: 每次增删改查完之后, 清空一下
@@ -1333,51 +1576,17 @@ func (session *Session)clearSession() {
 
 	session.prepare = ""
 
-}
+	session.verb = ""
+	session.duplicateCols = nil
+	session.duplicateData = nil
+	session.duplicate = false
+	session.keyMode = nil
+	session.incrs = nil
+	session.allowFullTable = false
+	session.whereTrueGroups = nil
+	session.model = nil
+	session.unscoped = false
 
-func (session *Session)printSql(sql string) {
-
-	ss := strings.Split(sql, "?")
-
-	newSql := ""
-	for i, s := range ss {
-
-		newSql += " " + s
-		if i < len(session.args) {
-			a := session.args[i]
-
-			if n, ok := a.(string); ok {
-				newSql += " " + n
-			} else if n, ok := a.(int); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(int8); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(int16); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(int32); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(int64); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(uint); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(uint8); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(uint16); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(uint32); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(uint64); ok {
-				newSql += " " + strconv.FormatInt(int64(n), 10)
-			} else if n, ok := a.(float32); ok {
-				newSql += " " + strconv.FormatFloat(float64(n), 'f', -1, 32)
-			} else if n, ok := a.(float64); ok {
-				newSql += " " + strconv.FormatFloat(n, 'f', -1, 64)
-			} else if n, ok := a.(bool); ok {
-				newSql += " " + strconv.FormatBool(n)
-			}
-		}
-	}
-
-	log.Println(newSql)
+	session.err = nil
 
-}
\ No newline at end of file
+}
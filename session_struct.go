@@ -0,0 +1,337 @@
+package zyorm
+
+import (
+	"errors"
+	"reflect"
+)
+
+//用结构体插入一条数据, 字段按 zyfield 等 tag 对应, zyfield:"-" 和 zytable 指定为其他表的字段不写入
+//主键为零值时不写入主键, 插入成功后把自增 id 写回结构体
+func (session *Session) InsertStruct(p interface{}) (int64, error) {
+
+	tableInfo, v, err := session.structValue(p)
+	if err != nil {
+		session.clearSession()
+		return 0, err
+	}
+
+	defer session.useStructTable(tableInfo)()
+
+	pk, hasPk := tableInfo.Fields[tableInfo.Pk]
+
+	if err = session.beforeInsert(v); err != nil {
+		session.clearSession()
+		return 0, err
+	}
+
+	session.setStructTimestamps(tableInfo, v, true)
+
+	data := session.structData(tableInfo, v, false)
+
+	lastInsertId, err := session.Insert(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if hasPk {
+		setPkValue(v.FieldByName(pk.AttrName), lastInsertId)
+	}
+
+	if err = session.afterInsert(v); err != nil {
+		return lastInsertId, err
+	}
+
+	return lastInsertId, nil
+}
+
+//用结构体切片批量插入, p 为 *[]T 或 *[]*T, 返回影响行数
+//主键为零值时会把自增 id 写回结构体, mysql 下依赖同一条 insert 语句的自增 id 连续分配
+//使用 InsertIgnore/Replace/OnDuplicateKeyUpdate 等时不写回 id
+func (session *Session) InsertStructs(p interface{}) (int64, error) {
+
+	sliceV := reflect.ValueOf(p)
+	if sliceV.Kind() != reflect.Ptr || sliceV.Elem().Kind() != reflect.Slice {
+		session.clearSession()
+		return 0, errors.New("参数不是结构体切片指针")
+	}
+	sliceV = sliceV.Elem()
+
+	if sliceV.Len() < 1 {
+		session.clearSession()
+		return 0, errors.New("参数没有数据")
+	}
+
+	elemT := sliceV.Type().Elem()
+	if elemT.Kind() == reflect.Ptr {
+		elemT = elemT.Elem()
+	}
+
+	if elemT.Kind() != reflect.Struct {
+		session.clearSession()
+		return 0, errors.New("参数不是结构体切片指针")
+	}
+
+	tableInfo, err := session.Engine.getTable(elemT)
+	if err != nil {
+		session.clearSession()
+		return 0, err
+	}
+
+	defer session.useStructTable(tableInfo)()
+
+	values := make([]reflect.Value, 0, sliceV.Len())
+	datas := make([]map[string]interface{}, 0, sliceV.Len())
+
+	for i := 0; i < sliceV.Len(); i++ {
+		v := reflect.Indirect(sliceV.Index(i))
+		if !v.IsValid() {
+			session.clearSession()
+			return 0, errors.New("切片中有 nil 元素")
+		}
+
+		//先执行所有行的钩子, 任何一行返回错误都不插入
+		if err = session.beforeInsert(v); err != nil {
+			session.clearSession()
+			return 0, err
+		}
+
+		values = append(values, v)
+	}
+
+	//插入多行时主键要么都写, 要么都不写, 以第一行为准, 钩子中可能设置了主键, 所以在钩子之后判断
+	withPk := false
+	if pk, ok := tableInfo.Fields[tableInfo.Pk]; ok {
+		withPk = !values[0].FieldByName(pk.AttrName).IsZero()
+	}
+
+	for _, v := range values {
+		session.setStructTimestamps(tableInfo, v, true)
+
+		datas = append(datas, session.structData(tableInfo, v, withPk))
+	}
+
+	pk, hasPk := tableInfo.Fields[tableInfo.Pk]
+
+	//INSERT IGNORE/REPLACE/ON DUPLICATE KEY UPDATE 时有的行没有插入或者更新了已有的行, 自增 id 不连续, 不写回 id
+	withIds := hasPk && !withPk && !session.duplicate && len(session.verb) < 1
+
+	ret, err := session.insertAll(datas, withIds)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ret.ids) == len(values) {
+		for i, v := range values {
+			setPkValue(v.FieldByName(pk.AttrName), ret.ids[i])
+		}
+	}
+
+	for _, v := range values {
+		if err = session.afterInsert(v); err != nil {
+			return ret.rowsAffected, err
+		}
+	}
+
+	return ret.rowsAffected, nil
+}
+
+//用结构体更新数据, 根据主键(zypk 或 id 字段)生成 where, 除主键外本表的字段都会更新, 返回影响行数
+//有 zyversion 字段时使用乐观锁, 版本号不一致没有更新数据时返回 ErrStaleObject, 更新成功后版本号加 1 写回结构体
+func (session *Session) UpdateStruct(p interface{}) (int64, error) {
+
+	tableInfo, v, err := session.structValue(p)
+	if err != nil {
+		session.clearSession()
+		return 0, err
+	}
+
+	pk, ok := tableInfo.Fields[tableInfo.Pk]
+	if !ok {
+		session.clearSession()
+		return 0, errors.New("结构体没有主键, 请用 zypk 指定")
+	}
+
+	pkV := v.FieldByName(pk.AttrName)
+	if pkV.IsZero() {
+		session.clearSession()
+		return 0, errors.New("主键没有值")
+	}
+
+	defer session.useStructTable(tableInfo)()
+
+	if err = session.beforeUpdate(v); err != nil {
+		session.clearSession()
+		return 0, err
+	}
+
+	session.setStructTimestamps(tableInfo, v, false)
+
+	data := session.structData(tableInfo, v, false)
+	delete(data, pk.FieldName)
+
+	//创建时间没有值时不更新, 防止没有查出来的创建时间被覆盖
+	if created, ok := tableInfo.Fields[tableInfo.Created]; ok && v.FieldByName(created.AttrName).IsZero() {
+		delete(data, created.FieldName)
+	}
+
+	wheres := map[string]interface{}{
+		pk.FieldName: pkV.Interface(),
+	}
+
+	//有 zyversion 字段时, 只更新版本号没有变化的数据, 同时版本号加 1
+	version, hasVersion := tableInfo.Fields[tableInfo.Version]
+	var versionV reflect.Value
+
+	if hasVersion {
+		versionV = v.FieldByName(version.AttrName)
+		wheres[version.FieldName] = versionV.Interface()
+		data[version.FieldName] = Expr(session.quote(version.FieldName) + " + 1")
+	}
+
+	session.Where(wheres)
+
+	rowsAffected, err := session.Update(data)
+	if err != nil {
+		return 0, err
+	}
+
+	//DryRun 时没有真正更新, 不检查也不修改版本号
+	if hasVersion && !session.isDryRun() {
+		if rowsAffected < 1 {
+			return 0, ErrStaleObject
+		}
+		incrVersion(versionV)
+	}
+
+	if err = session.afterUpdate(v); err != nil {
+		return rowsAffected, err
+	}
+
+	return rowsAffected, nil
+}
+
+//乐观锁检查失败: 数据已经被其他人修改或删除, 需要重新查询后再更新
+var ErrStaleObject = errors.New("zyorm: 数据版本已过期, 没有更新任何数据")
+
+//版本号加 1 写回结构体
+func incrVersion(f reflect.Value) {
+
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(f.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(f.Uint() + 1)
+	}
+}
+
+//获取结构体指针对应的表信息和结构体的 Value
+func (session *Session) structValue(p interface{}) (TableInfo, reflect.Value, error) {
+
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return TableInfo{}, reflect.Value{}, errors.New("参数不是结构体指针")
+	}
+	v = v.Elem()
+
+	tableInfo, err := session.Engine.getTable(v.Type())
+	if err != nil {
+		return TableInfo{}, reflect.Value{}, err
+	}
+
+	return tableInfo, v, nil
+}
+
+//没有调用 Table 时使用结构体对应的表名和主键, 返回的函数用于执行完后恢复
+func (session *Session) useStructTable(tableInfo TableInfo) func() {
+
+	tableName := session.TableName
+	pk := session.pk
+
+	if len(tableName) < 1 {
+		session.TableName = tableInfo.Name
+	}
+
+	if len(pk) < 1 {
+		if pkInfo, ok := tableInfo.Fields[tableInfo.Pk]; ok {
+			session.pk = pkInfo.FieldName
+		}
+	}
+
+	return func() {
+		session.TableName = tableName
+		session.pk = pk
+	}
+}
+
+//把结构体中属于本表的字段转成 字段名 => 值, withPk 为 false 时, 主键是零值就不写入
+func (session *Session) structData(tableInfo TableInfo, v reflect.Value, withPk bool) map[string]interface{} {
+
+	data := make(map[string]interface{}, len(tableInfo.FieldOrder))
+
+	for _, asName := range tableInfo.FieldOrder {
+
+		fieldInfo := tableInfo.Fields[asName]
+
+		//join 进来的其他表的字段不写入
+		if fieldInfo.TableName != tableInfo.Name {
+			continue
+		}
+
+		f := v.FieldByName(fieldInfo.AttrName)
+		if !f.IsValid() {
+			continue
+		}
+
+		if asName == tableInfo.Pk && !withPk && f.IsZero() {
+			continue
+		}
+
+		//软删除字段由 Delete 维护, 不写入
+		//查出来的 NULL 会被设置成 time.Unix(0,0), 不是零值, 写入后数据会被当成已删除
+		if fieldInfo.FieldName == tableInfo.Deleted {
+			continue
+		}
+
+		//zysensitive 字段输出 sql 时隐藏值
+		if fieldInfo.Sensitive {
+			data[fieldInfo.FieldName] = Sensitive(f.Interface())
+			continue
+		}
+
+		data[fieldInfo.FieldName] = f.Interface()
+	}
+
+	return data
+}
+
+//把自增 id 写回主键字段, 主键已经有值或不是整数类型时不处理
+func setPkValue(f reflect.Value, id int64) {
+
+	if !f.IsValid() || !f.CanSet() || !f.IsZero() || id < 1 {
+		return
+	}
+
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(id))
+	}
+}
+
+//根据多行 insert 的 LastInsertId 推算每一行的 id
+//mysql 返回的是第一行的 id, sqlite 返回的是最后一行的 id
+func insertIds(dialect Dialect, lastInsertId int64, n int) []int64 {
+
+	first := lastInsertId
+	if _, ok := dialect.(sqliteDialect); ok {
+		first = lastInsertId - int64(n) + 1
+	}
+
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = first + int64(i)
+	}
+
+	return ids
+}
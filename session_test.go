@@ -0,0 +1,89 @@
+package zyorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type whereStatus int
+
+func TestWhereValue(t *testing.T) {
+
+	n := 5
+	s := "tom"
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		wantSql  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{"nil", nil, "IS NULL", nil, false},
+		{"整数", 1, "=?", []interface{}{1}, false},
+		{"字符串", "tom", "=?", []interface{}{"tom"}, false},
+		{"自定义整数类型", whereStatus(2), "=?", []interface{}{whereStatus(2)}, false},
+		{"整数指针", &n, "=?", []interface{}{&n}, false},
+		{"字符串指针", &s, "=?", []interface{}{&s}, false},
+		{"敏感数据", Sensitive("x"), "=?", []interface{}{Sensitive("x")}, false},
+		{"IS NULL", []interface{}{"is null"}, "IS NULL", nil, false},
+		{"IS NOT NULL", []interface{}{"is  not\tnull"}, "IS NOT NULL", nil, false},
+		{"= nil", []interface{}{"=", nil}, "IS NULL", nil, false},
+		{"<> nil", []interface{}{"<>", nil}, "IS NOT NULL", nil, false},
+		{"!= nil", []interface{}{"!=", nil}, "IS NOT NULL", nil, false},
+		{"LIKE nil", []interface{}{"like", nil}, "", nil, true},
+		{">", []interface{}{">", whereStatus(1)}, "> ? ", []interface{}{whereStatus(1)}, false},
+		{"NOT LIKE", []interface{}{"not like", "%a%"}, "NOT LIKE ? ", []interface{}{"%a%"}, false},
+		{"REGEXP", []interface{}{"regexp", "^a"}, "REGEXP ? ", []interface{}{"^a"}, false},
+		{"比较切片", []interface{}{"=", []int{1}}, "", nil, true},
+		{"IN 单个值", []interface{}{"in", 1}, "IN (?) ", []interface{}{1}, false},
+		{"NOT IN 切片", []interface{}{"not in", []int{1, 2}}, "NOT IN (?,?) ", []interface{}{1, 2}, false},
+		{"NOT IN 自定义类型的切片", []interface{}{"not in", []whereStatus{1, 2}}, "NOT IN (?,?) ", []interface{}{whereStatus(1), whereStatus(2)}, false},
+		{"NOT IN 逗号分隔的字符串", []interface{}{"NOT IN", "a,b,c"}, "NOT IN (?,?,?) ", []interface{}{"a", "b", "c"}, false},
+		{"NOT IN 空切片", []interface{}{"not in", []int{}}, "", nil, true},
+		{"IN 缺少参数", []interface{}{"in"}, "", nil, true},
+		{"BETWEEN 两个参数", []interface{}{"between", 1, 10}, "BETWEEN ? and ? ", []interface{}{1, 10}, false},
+		{"BETWEEN 切片", []interface{}{"between", []int{1, 10}}, "BETWEEN ? and ? ", []interface{}{1, 10}, false},
+		{"NOT BETWEEN 两个参数", []interface{}{"not between", "a", "z"}, "NOT BETWEEN ? and ? ", []interface{}{"a", "z"}, false},
+		{"NOT BETWEEN 切片", []interface{}{"not between", []string{"a", "z"}}, "NOT BETWEEN ? and ? ", []interface{}{"a", "z"}, false},
+		{"BETWEEN 参数数量不对", []interface{}{"between", []int{1, 2, 3}}, "", nil, true},
+		{"BETWEEN 参数类型不对", []interface{}{"between", []int{1}, 2}, "", nil, true},
+		{"未知的操作符", []interface{}{"~~", 1}, "", nil, true},
+		{"空条件", []interface{}{}, "", nil, true},
+		{"操作符不是字符串", []interface{}{1, 2}, "", nil, true},
+		{"不支持的类型", map[string]int{"a": 1}, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sql, args, err := whereValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if sql != tt.wantSql || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("whereValue() = %q, %v, want %q, %v", sql, args, tt.wantSql, tt.wantArgs)
+			}
+		})
+	}
+}
+
+//Where 和 Insert/Update 一样接受自定义类型和指针
+func TestWhereCustomType(t *testing.T) {
+
+	engine := NewEngineFromDB(nil, MySQL)
+	engine.DryRun = true
+
+	n := 1
+	session := engine.Table("t")
+	_, err := session.Where(map[string]interface{}{"status": whereStatus(1), "id": &n}).Update(map[string]interface{}{"status": whereStatus(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt := session.LastStatement()
+	if len(stmt.Args) != 3 {
+		t.Errorf("args = %v", stmt.Args)
+	}
+}
@@ -0,0 +1,116 @@
+package zyorm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+//指定本次会话操作的模型, 可以是结构体、结构体指针或结构体切片指针, 没有调用 Table 时使用模型的表名
+//模型有 zydeleted 字段时, Delete 改为更新删除时间, Count 不统计已删除的数据
+//和 where 等条件一样只对下一条语句有效, 执行后会被清空
+func (session *Session) Model(p interface{}) *Session {
+
+	t := reflect.TypeOf(p)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		session.setErr(errors.New("Model 参数不是结构体"))
+		return session
+	}
+
+	tableInfo, err := session.Engine.getTable(t)
+	if err != nil {
+		session.setErr(err)
+		return session
+	}
+
+	session.model = p
+
+	if len(session.TableName) < 1 {
+		session.TableName = tableInfo.Name
+	}
+
+	return session
+}
+
+//不处理软删除: 查询包含已删除的数据, Delete 真正删除数据
+func (session *Session) Unscoped() *Session {
+	session.unscoped = true
+	return session
+}
+
+//真正删除数据, 不管模型有没有 zydeleted 字段
+func (session *Session) ForceDelete() (int64, error) {
+	return session.Unscoped().Delete()
+}
+
+//Model 指定的模型对应的结构体类型, 没有指定时返回 nil
+func (session *Session) modelType() reflect.Type {
+
+	if session.model == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(session.model)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+//Model 指定的模型的表信息
+func (session *Session) modelTable() (TableInfo, bool) {
+
+	t := session.modelType()
+	if t == nil {
+		return TableInfo{}, false
+	}
+
+	tableInfo, err := session.Engine.getTable(t)
+	if err != nil {
+		return TableInfo{}, false
+	}
+
+	return tableInfo, true
+}
+
+//Delete 时需要改为更新的软删除字段, 不需要软删除时返回空字符串
+func (session *Session) softDeleteColumn() string {
+
+	if session.unscoped {
+		return ""
+	}
+
+	tableInfo, ok := session.modelTable()
+	if !ok {
+		return ""
+	}
+
+	return tableInfo.Deleted
+}
+
+//在 where 后面加上软删除字段 IS NULL 的条件, from 为 FROM 后面的表名, 可以带别名, 字段用别名或表名限定
+func (session *Session) withSoftDeleteWhere(where string, tableInfo TableInfo, from string) string {
+
+	if session.unscoped || len(tableInfo.Deleted) < 1 {
+		return where
+	}
+
+	//users, users u, users AS u 都取最后一个
+	qualifier := tableInfo.Name
+	if words := strings.Fields(from); len(words) > 0 {
+		qualifier = words[len(words)-1]
+	}
+
+	cond := qualifier + "." + session.quote(tableInfo.Deleted) + " IS NULL"
+
+	if len(where) < 1 {
+		return " " + cond
+	}
+
+	return " (" + where + ") and " + cond
+}
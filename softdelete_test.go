@@ -0,0 +1,310 @@
+package zyorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//测试用的驱动, 记录执行的语句, 查询返回 stubRows 中的数据
+type stubDriver struct {
+	mu      sync.Mutex
+	execs   []stubExec
+	columns []string
+	rows    [][]driver.Value
+
+	//exec 语句的结果, 为 nil 时影响 1 行, 自增 id 为 1
+	result driver.Result
+}
+
+type stubExec struct {
+	query string
+	args  []driver.Value
+}
+
+type stubConn struct{ d *stubDriver }
+
+type stubStmt struct {
+	d     *stubDriver
+	query string
+}
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+var stubSeq struct {
+	sync.Mutex
+	n int
+}
+
+//注册一个新的驱动并打开, 每个测试使用自己的驱动
+func openStub(t *testing.T) (*sql.DB, *stubDriver) {
+
+	d := &stubDriver{}
+
+	stubSeq.Lock()
+	stubSeq.n++
+	name := "zyorm_stub_" + strconv.Itoa(stubSeq.n)
+	stubSeq.Unlock()
+
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, d
+}
+
+func (d *stubDriver) Open(name string) (driver.Conn, error) {
+	return stubConn{d: d}, nil
+}
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) {
+	return stubStmt{d: c.d, query: query}, nil
+}
+
+func (c stubConn) Close() error {
+	return nil
+}
+
+func (c stubConn) Begin() (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return c, nil
+}
+
+func (c stubConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return c, nil
+}
+
+func (c stubConn) Commit() error {
+	c.d.record("COMMIT")
+	return nil
+}
+
+func (c stubConn) Rollback() error {
+	c.d.record("ROLLBACK")
+	return nil
+}
+
+//记录事务的开启/提交/回滚
+func (d *stubDriver) record(query string) {
+	d.mu.Lock()
+	d.execs = append(d.execs, stubExec{query: query})
+	d.mu.Unlock()
+}
+
+//按顺序执行过的语句
+func (d *stubDriver) queries() []string {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var queries []string
+	for _, exec := range d.execs {
+		queries = append(queries, exec.query)
+	}
+
+	return queries
+}
+
+func (s stubStmt) Close() error {
+	return nil
+}
+
+func (s stubStmt) NumInput() int {
+	return -1
+}
+
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	s.d.execs = append(s.d.execs, stubExec{query: s.query, args: args})
+
+	if s.d.result != nil {
+		return s.d.result, nil
+	}
+
+	return stubResult{lastInsertId: 1, rowsAffected: 1}, nil
+}
+
+//自增 id 和影响行数固定的结果
+type stubResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r stubResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r stubResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func (s stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	return &stubRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+func (r *stubRows) Columns() []string {
+	return r.columns
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+
+	if len(r.rows) < 1 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+type softUser struct {
+	Id        int64
+	Name      string
+	DeletedAt *time.Time `zydeleted:"deleted_at"`
+}
+
+//Find 查出来的数据用 UpdateStruct 写回时不能修改软删除字段, 否则会把数据删除或恢复
+func TestFindUpdateStructKeepsDeleted(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		unscoped  bool
+		deletedAt driver.Value
+	}{
+		{"没有删除的数据", false, nil},
+		{"Unscoped 查出已删除的数据", true, []byte("2020-01-02 03:04:05")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db, d := openStub(t)
+			engine := NewEngineFromDB(db, MySQL)
+
+			d.columns = []string{"id", "name", "deleted_at"}
+			d.rows = [][]driver.Value{{[]byte("1"), []byte("tom"), tt.deletedAt}}
+
+			session := engine.NewSession()
+			if tt.unscoped {
+				session.Unscoped()
+			}
+
+			var user softUser
+			has, err := session.Find(&user)
+			if err != nil || !has {
+				t.Fatalf("Find = %v, %v", has, err)
+			}
+
+			if (user.DeletedAt != nil) != (tt.deletedAt != nil) {
+				t.Fatalf("DeletedAt = %v", user.DeletedAt)
+			}
+
+			user.Name = "jerry"
+
+			if _, err = engine.NewSession().UpdateStruct(&user); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(d.execs) != 1 {
+				t.Fatalf("execs = %d, want 1", len(d.execs))
+			}
+
+			exec := d.execs[0]
+			if !strings.HasPrefix(exec.query, "UPDATE") {
+				t.Fatalf("query = %q", exec.query)
+			}
+
+			set := exec.query[:strings.Index(exec.query, "WHERE")]
+			if strings.Contains(set, "deleted_at") {
+				t.Errorf("UPDATE 修改了软删除字段: %q", exec.query)
+			}
+			if !strings.Contains(set, "name") {
+				t.Errorf("UPDATE 没有修改 name: %q", exec.query)
+			}
+
+			for _, arg := range exec.args {
+				if _, ok := arg.(time.Time); ok {
+					t.Errorf("UPDATE 的参数中有时间: %v", exec.args)
+				}
+			}
+
+			if len(exec.args) != 2 || exec.args[0] != "jerry" || exec.args[1] != int64(1) {
+				t.Errorf("args = %v, want [jerry 1]", exec.args)
+			}
+		})
+	}
+}
+
+//软删除条件用 FROM 中的表名或别名限定字段
+func TestCountSoftDeleteTable(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		count func(engine *Engine) *Session
+		want  string
+	}{
+		{"模型的表", func(engine *Engine) *Session {
+			session := engine.NewSession()
+			session.Model(&softUser{}).Count()
+			return session
+		}, "SELECT COUNT(*) c FROM softuser WHERE  softuser.`deleted_at` IS NULL"},
+		{"Table 指定的表", func(engine *Engine) *Session {
+			session := engine.Table("users")
+			session.Model(&softUser{}).Count()
+			return session
+		}, "SELECT COUNT(*) c FROM users WHERE  users.`deleted_at` IS NULL"},
+		{"表的别名", func(engine *Engine) *Session {
+			session := engine.Table("users u")
+			session.Model(&softUser{}).Where(map[string]interface{}{"u.name": "tom"}).Count()
+			return session
+		}, "SELECT COUNT(*) c FROM users u WHERE  ( ( u.`name` =?)) and u.`deleted_at` IS NULL"},
+		{"TypedQuery", func(engine *Engine) *Session {
+			q := Query[softUser](engine)
+			q.Count(context.Background())
+			return q.Session()
+		}, "SELECT COUNT(*) c FROM softuser WHERE  softuser.`deleted_at` IS NULL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			engine := NewEngineFromDB(nil, MySQL)
+			engine.DryRun = true
+
+			stmt := tt.count(engine).LastStatement()
+			if stmt == nil {
+				t.Fatal("没有生成语句")
+			}
+
+			if stmt.SQL != tt.want {
+				t.Errorf("SQL = %s, want %s", stmt.SQL, tt.want)
+			}
+		})
+	}
+}
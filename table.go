@@ -9,6 +9,16 @@ type TableInfo struct {
 	RWRuField *sync.RWMutex
 	Fields map[string]FieldInfo
 
+	FieldOrder []string //字段别名, 按结构体中定义的顺序
+
+	Pk string //主键字段的别名, 由 zypk tag 指定, 没有指定时使用字段名为 id 的字段
+
+	Deleted string //软删除的字段名, 由 zydeleted tag 指定
+
+	Version string //乐观锁版本号字段的别名, 由 zyversion tag 指定
+
+	Created string //创建时间字段的别名, 由 zycreated tag 指定
+	Updated string //更新时间字段的别名, 由 zyupdated tag 指定
 
 }
 
@@ -18,4 +28,6 @@ type FieldInfo struct {
 	FieldName string //字段名
 	AsName string //别名
 	TableName string //表名
+	IsPk bool //是否是主键
+	Sensitive bool //是否是敏感字段, 由 zysensitive tag 指定, 输出 sql 时不显示值
 }
\ No newline at end of file
@@ -0,0 +1,143 @@
+package zyorm
+
+import (
+	"reflect"
+	"time"
+)
+
+//当前时间, 按 Engine.NowFunc 和 Engine.TimeZone 获取
+func (engine *Engine) now() time.Time {
+
+	var now time.Time
+	if engine.NowFunc != nil {
+		now = engine.NowFunc()
+	} else {
+		now = time.Now()
+	}
+
+	return now.In(engine.location())
+}
+
+//写入和读取时间使用的时区, 没有设置 TimeZone 时和 mysql 驱动的默认值一样使用 UTC
+//驱动按 dsn 的 loc 写入 time.Time, 这里需要用相同的时区解析读出来的时间, 否则会差一个时区
+func (engine *Engine) location() *time.Location {
+	if engine.TimeZone != nil {
+		return engine.TimeZone
+	}
+	return time.UTC
+}
+
+//解析数据库返回的时间字符串, 没有时区的按 Engine.TimeZone 解析
+func (engine *Engine) parseTime(value string) (time.Time, error) {
+
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", value, engine.location())
+	if err == nil {
+		return t, nil
+	}
+
+	//dsn 中设置了 parseTime=true 时, 驱动返回的时间会被转成 RFC3339 格式
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+//Model 指定的模型有 zycreated/zyupdated 字段且 data 中没有时, 返回加上当前时间的新 map, 不修改 data
+//isInsert 为 false 时只处理 zyupdated
+func (session *Session) withTimestamps(data map[string]interface{}, isInsert bool) map[string]interface{} {
+
+	tableInfo, ok := session.modelTable()
+	if !ok {
+		return data
+	}
+
+	var fields []FieldInfo
+	if isInsert && len(tableInfo.Created) > 0 {
+		fields = append(fields, tableInfo.Fields[tableInfo.Created])
+	}
+	if len(tableInfo.Updated) > 0 {
+		fields = append(fields, tableInfo.Fields[tableInfo.Updated])
+	}
+
+	var filled map[string]interface{}
+	now := session.Engine.now()
+	t := session.modelType()
+
+	for _, fieldInfo := range fields {
+		col := fieldInfo.FieldName
+		if _, ok := data[col]; ok {
+			continue
+		}
+
+		if filled == nil {
+			filled = make(map[string]interface{}, len(data)+len(fields))
+			for k, v := range data {
+				filled[k] = v
+			}
+		}
+
+		//和结构体一样按字段类型写入, 整数字段写入时间戳
+		filled[col] = now
+		if field, ok := t.FieldByName(fieldInfo.AttrName); ok {
+			if value, ok := timeValue(field.Type, now); ok {
+				filled[col] = value.Interface()
+			}
+		}
+	}
+
+	if filled == nil {
+		return data
+	}
+
+	return filled
+}
+
+//结构体写入前设置 zycreated/zyupdated 字段, isInsert 为 true 时只设置零值的字段, 为 false 时只更新 zyupdated
+func (session *Session) setStructTimestamps(tableInfo TableInfo, v reflect.Value, isInsert bool) {
+
+	now := session.Engine.now()
+
+	if isInsert && len(tableInfo.Created) > 0 {
+		f := v.FieldByName(tableInfo.Fields[tableInfo.Created].AttrName)
+		if f.IsValid() && f.IsZero() {
+			setTimeValue(f, now)
+		}
+	}
+
+	if len(tableInfo.Updated) > 0 {
+		f := v.FieldByName(tableInfo.Fields[tableInfo.Updated].AttrName)
+		if f.IsValid() && (!isInsert || f.IsZero()) {
+			setTimeValue(f, now)
+		}
+	}
+}
+
+//按字段类型写入时间, 支持 time.Time, *time.Time 和整数(秒级时间戳)
+func setTimeValue(f reflect.Value, now time.Time) {
+
+	if !f.CanSet() {
+		return
+	}
+
+	if value, ok := timeValue(f.Type(), now); ok {
+		f.Set(value)
+	}
+}
+
+//把时间转成字段类型的值, 不支持的类型返回 false
+func timeValue(t reflect.Type, now time.Time) (reflect.Value, bool) {
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == timeType {
+			return reflect.ValueOf(now), true
+		}
+	case reflect.Ptr:
+		if t.Elem() == timeType {
+			return reflect.ValueOf(&now), true
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(now.Unix()).Convert(t), true
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(uint64(now.Unix())).Convert(t), true
+	}
+
+	return reflect.Value{}, false
+}
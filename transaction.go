@@ -0,0 +1,219 @@
+package zyorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	//没有调用 Begin 就 Commit/Rollback
+	ErrTxNotBegin = errors.New("zyorm: 没有开启事务, 请先调用 Begin")
+
+	//事务已经提交或回滚后, 再次 Commit/Rollback
+	ErrTxDone = errors.New("zyorm: 事务已经提交或回滚")
+)
+
+//在事务中执行 fn, fn 返回 nil 时提交, 返回错误或 panic 时回滚, panic 会在回滚后继续抛出
+func (engine *Engine) Transaction(fn func(session *Session) error) error {
+	session := engine.createSession()
+	return session.Transaction(fn)
+}
+
+//在事务中执行 fn, 规则同 Engine.Transaction, 会使用会话上设置的 context
+//会话已经在事务中时, fn 在 SAVEPOINT 中执行, 出错只回滚到这个 SAVEPOINT
+func (session *Session) Transaction(fn func(session *Session) error) error {
+
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	return session.runTx(fn)
+}
+
+//执行 fn 并根据结果提交或回滚, 调用前需要已经 Begin
+func (session *Session) runTx(fn func(session *Session) error) (err error) {
+
+	panicked := true
+
+	defer func() {
+		if panicked {
+			if rbErr := session.Rollback(); rbErr != nil {
+				session.log(LevelError, "rollback error", Field{"error", rbErr})
+			}
+		}
+	}()
+
+	err = fn(session)
+	panicked = false
+
+	if err != nil {
+		if rbErr := session.Rollback(); rbErr != nil {
+			session.log(LevelError, "rollback error", Field{"error", rbErr})
+		}
+		return err
+	}
+
+	return session.Commit()
+}
+
+//检查是否有可以提交/回滚的事务
+func (session *Session) checkTx() error {
+
+	if session.Tx != nil || session.dryRunTx {
+		return nil
+	}
+
+	if session.txDone {
+		return ErrTxDone
+	}
+
+	return ErrTxNotBegin
+}
+
+//经过拦截器提交或回滚最外层的事务, DryRun 时没有 Tx, 只记录语句
+//拦截器返回错误时事务也结束, 没有提交的事务由 database/sql 在连接归还时处理
+func (session *Session) endTx(op string, sqlstr string, end func(tx *sql.Tx) error) error {
+
+	tx := session.Tx
+
+	_, err := session.run(&Statement{Op: op, SQL: sqlstr, tx: func(ctx context.Context) error {
+		if tx == nil {
+			return nil
+		}
+		return end(tx)
+	}})
+
+	session.finishTx()
+
+	return err
+}
+
+//事务提交或回滚后, 之后的操作不再使用这个事务
+func (session *Session) finishTx() {
+	session.Tx = nil
+	session.dryRunTx = false
+	session.txDepth = 0
+	session.txDone = true
+}
+
+//当前嵌套事务的层数, 没有事务或在最外层事务中时为 0
+func (session *Session) TxDepth() int {
+	return session.txDepth
+}
+
+//执行 SAVEPOINT / ROLLBACK TO SAVEPOINT / RELEASE SAVEPOINT, depth 对应 savepoint 的名字 zyorm_N
+func (session *Session) execSavepoint(action string, depth int) error {
+
+	sqlstr := action + " zyorm_" + strconv.Itoa(depth)
+
+	//savepoint 相关语句不支持预处理, 直接执行
+	_, err := session.run(&Statement{Op: OpSavepoint, SQL: sqlstr, direct: true})
+
+	return err
+}
+
+//TransactionWithRetry 的重试设置
+type RetryOptions struct {
+
+	//事务的隔离级别/只读等选项, 为 nil 时使用数据库默认设置
+	TxOptions *sql.TxOptions
+
+	//最多执行几次(包括第一次), 小于 1 时为 3
+	MaxAttempts int
+
+	//第一次重试前的等待时间, 之后每次翻倍, 为 0 时为 50ms
+	Backoff time.Duration
+
+	//最长等待时间, 为 0 时为 1s
+	MaxBackoff time.Duration
+
+	//判断错误是否需要重试, 为 nil 时使用 IsRetryableTxError
+	Retryable func(err error) bool
+}
+
+//在事务中执行 fn, 遇到死锁(1213)或锁等待超时(1205)时回滚并重新执行整个 fn
+//fn 可能会执行多次, 里面不要有事务之外的副作用
+func (engine *Engine) TransactionWithRetry(opts RetryOptions, fn func(session *Session) error) error {
+	session := engine.createSession()
+	return session.TransactionWithRetry(opts, fn)
+}
+
+//同 Engine.TransactionWithRetry, 会使用会话上设置的 context, 不能在已有的事务中调用
+func (session *Session) TransactionWithRetry(opts RetryOptions, fn func(session *Session) error) error {
+
+	if session.Tx != nil {
+		return errors.New("zyorm: 已经在事务中, 不能重试整个事务")
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsRetryableTxError
+	}
+
+	for attempt := 1; ; attempt++ {
+
+		err := session.BeginTx(opts.TxOptions)
+		if err == nil {
+			err = session.runTx(fn)
+		}
+
+		if err == nil || attempt >= maxAttempts || !retryable(err) {
+			return err
+		}
+
+		session.log(LevelWarn, "transaction retry", Field{"attempt", attempt}, Field{"max_retries", maxAttempts - 1}, Field{"error", err})
+
+		timer := time.NewTimer(opts.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-session.context().Done():
+			timer.Stop()
+			return session.wrapCtxErr(session.context().Err())
+		}
+	}
+}
+
+//第 retry 次重试前的等待时间, 从 Backoff 开始每次翻倍, 不超过 MaxBackoff
+func (opts RetryOptions) backoff(retry int) time.Duration {
+
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = 50 * time.Millisecond
+	}
+
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
+
+//是否是可以重试整个事务的错误: mysql 死锁(1213)或锁等待超时(1205)
+func IsRetryableTxError(err error) bool {
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	return false
+}
@@ -0,0 +1,279 @@
+package zyorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//嵌套事务用 SAVEPOINT 实现, 内层出错只回滚到对应的 SAVEPOINT
+func TestNestedTransaction(t *testing.T) {
+
+	innerErr := errors.New("inner")
+
+	tests := []struct {
+		name    string
+		fn      func(session *Session) error
+		wantErr error
+		want    []string
+	}{
+		{"都成功", func(session *Session) error {
+			return session.Transaction(func(session *Session) error {
+				return nil
+			})
+		}, nil, []string{"BEGIN", "SAVEPOINT zyorm_1", "RELEASE SAVEPOINT zyorm_1", "COMMIT"}},
+
+		{"内层出错外层继续", func(session *Session) error {
+			if err := session.Transaction(func(session *Session) error {
+				return innerErr
+			}); err != innerErr {
+				t.Errorf("inner err = %v", err)
+			}
+			return nil
+		}, nil, []string{"BEGIN", "SAVEPOINT zyorm_1", "ROLLBACK TO SAVEPOINT zyorm_1", "COMMIT"}},
+
+		{"内层出错外层返回", func(session *Session) error {
+			return session.Transaction(func(session *Session) error {
+				return innerErr
+			})
+		}, innerErr, []string{"BEGIN", "SAVEPOINT zyorm_1", "ROLLBACK TO SAVEPOINT zyorm_1", "ROLLBACK"}},
+
+		{"三层", func(session *Session) error {
+			return session.Transaction(func(session *Session) error {
+				if session.TxDepth() != 1 {
+					t.Errorf("TxDepth = %d, want 1", session.TxDepth())
+				}
+				session.Transaction(func(session *Session) error {
+					if session.TxDepth() != 2 {
+						t.Errorf("TxDepth = %d, want 2", session.TxDepth())
+					}
+					return innerErr
+				})
+				return nil
+			})
+		}, nil, []string{"BEGIN", "SAVEPOINT zyorm_1", "SAVEPOINT zyorm_2", "ROLLBACK TO SAVEPOINT zyorm_2", "RELEASE SAVEPOINT zyorm_1", "COMMIT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db, d := openStub(t)
+			engine := NewEngineFromDB(db, MySQL, WithLogger(NopLogger{}))
+
+			if err := engine.Transaction(tt.fn); err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := d.queries(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+//panic 时每一层都回滚, panic 继续抛出
+func TestNestedTransactionPanic(t *testing.T) {
+
+	db, d := openStub(t)
+	engine := NewEngineFromDB(db, MySQL, WithLogger(NopLogger{}))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recover() = %v, want boom", r)
+			}
+		}()
+
+		engine.Transaction(func(session *Session) error {
+			return session.Transaction(func(session *Session) error {
+				panic("boom")
+			})
+		})
+	}()
+
+	want := []string{"BEGIN", "SAVEPOINT zyorm_1", "ROLLBACK TO SAVEPOINT zyorm_1", "ROLLBACK"}
+	if got := d.queries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+}
+
+//手动 Begin/Rollback/Commit 时按层数处理
+func TestManualSavepoint(t *testing.T) {
+
+	db, d := openStub(t)
+	engine := NewEngineFromDB(db, MySQL)
+
+	session := engine.NewSession()
+
+	steps := []struct {
+		name  string
+		do    func() error
+		depth int
+	}{
+		{"Begin", session.Begin, 0},
+		{"Begin", session.Begin, 1},
+		{"Begin", session.Begin, 2},
+		{"Rollback", session.Rollback, 1},
+		{"Commit", session.Commit, 0},
+		{"Rollback", session.Rollback, 0},
+	}
+
+	for i, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%d %s: %v", i, step.name, err)
+		}
+		if session.TxDepth() != step.depth {
+			t.Errorf("%d %s: TxDepth = %d, want %d", i, step.name, session.TxDepth(), step.depth)
+		}
+	}
+
+	if err := session.Commit(); err != ErrTxDone {
+		t.Errorf("事务结束后 Commit = %v, want ErrTxDone", err)
+	}
+
+	want := []string{"BEGIN", "SAVEPOINT zyorm_1", "SAVEPOINT zyorm_2", "ROLLBACK TO SAVEPOINT zyorm_2", "RELEASE SAVEPOINT zyorm_1", "ROLLBACK"}
+	if got := d.queries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+}
+
+//死锁和锁等待超时时重新执行整个事务, 每次失败都回滚
+func TestTransactionWithRetry(t *testing.T) {
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	lockWait := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	tests := []struct {
+		name      string
+		opts      RetryOptions
+		errs      []error //每次执行 fn 返回的错误, 超出时返回 nil
+		wantCalls int
+		wantErr   error
+	}{
+		{"第一次成功", RetryOptions{}, nil, 1, nil},
+		{"死锁后成功", RetryOptions{}, []error{deadlock}, 2, nil},
+		{"锁等待超时后成功", RetryOptions{}, []error{lockWait, lockWait}, 3, nil},
+		{"默认最多 3 次", RetryOptions{}, []error{deadlock, deadlock, deadlock, deadlock}, 3, deadlock},
+		{"MaxAttempts", RetryOptions{MaxAttempts: 5}, []error{deadlock, deadlock, deadlock, deadlock, deadlock, deadlock}, 5, deadlock},
+		{"MaxAttempts 为 1 不重试", RetryOptions{MaxAttempts: 1}, []error{deadlock}, 1, deadlock},
+		{"其他错误不重试", RetryOptions{}, []error{duplicate}, 1, duplicate},
+		{"Retryable", RetryOptions{Retryable: func(err error) bool { return err == duplicate }}, []error{duplicate, deadlock}, 2, deadlock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db, d := openStub(t)
+			engine := NewEngineFromDB(db, MySQL, WithLogger(NopLogger{}))
+
+			tt.opts.Backoff = time.Microsecond
+
+			calls := 0
+			err := engine.TransactionWithRetry(tt.opts, func(session *Session) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+
+			//每次失败都回滚, 成功时最后一次提交
+			var want []string
+			for i := 1; i <= tt.wantCalls; i++ {
+				if i == tt.wantCalls && tt.wantErr == nil {
+					want = append(want, "BEGIN", "COMMIT")
+				} else {
+					want = append(want, "BEGIN", "ROLLBACK")
+				}
+			}
+
+			if got := d.queries(); !reflect.DeepEqual(got, want) {
+				t.Errorf("queries = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+
+	tests := []struct {
+		name string
+		opts RetryOptions
+		want []time.Duration
+	}{
+		{"默认值", RetryOptions{}, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}},
+		{"翻倍到上限", RetryOptions{Backoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}},
+		{"Backoff 超过上限", RetryOptions{Backoff: 2 * time.Second, MaxBackoff: time.Second}, []time.Duration{time.Second, time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				if got := tt.opts.backoff(i + 1); got != want {
+					t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+//等待重试时 context 被取消, 直接返回不再执行
+func TestTransactionWithRetryCancel(t *testing.T) {
+
+	db, d := openStub(t)
+	engine := NewEngineFromDB(db, MySQL, WithLogger(NopLogger{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+
+	err := engine.WithContext(ctx).TransactionWithRetry(RetryOptions{Backoff: time.Hour, MaxBackoff: time.Hour}, func(session *Session) error {
+		calls++
+		cancel()
+		return &mysql.MySQLError{Number: 1213}
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+
+	if calls != 1 || time.Since(start) > time.Minute {
+		t.Errorf("calls = %d, elapsed = %v", calls, time.Since(start))
+	}
+
+	want := []string{"BEGIN", "ROLLBACK"}
+	if got := d.queries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+}
+
+func TestTransactionWithRetryInTx(t *testing.T) {
+
+	db, _ := openStub(t)
+	engine := NewEngineFromDB(db, MySQL)
+
+	err := engine.Transaction(func(session *Session) error {
+		return session.TransactionWithRetry(RetryOptions{}, func(session *Session) error {
+			return nil
+		})
+	})
+
+	if err == nil {
+		t.Error("已经在事务中时应该返回错误")
+	}
+}
@@ -0,0 +1,150 @@
+package zyorm
+
+import (
+	"errors"
+	"strings"
+)
+
+//OnDuplicateKeyUpdate/UpdateWith/Upsert/InsertIgnore/Replace 等使用的是 mysql 的语法, 其他方言执行时返回错误
+
+//upsert 的结果, mysql 中每行新插入影响行数为 1, 更新为 2, 数据没有变化为 0
+type UpsertResult struct {
+	LastInsertId int64
+	RowsAffected int64
+}
+
+//单行 upsert 是否是新插入的
+func (r UpsertResult) Inserted() bool {
+	return r.RowsAffected == 1
+}
+
+//单行 upsert 是否更新了已有的数据
+func (r UpsertResult) Updated() bool {
+	return r.RowsAffected == 2
+}
+
+//插入时遇到唯一键冲突, 改为更新 cols 字段为新插入的值: col=VALUES(col)
+//cols 为空时更新所有插入的字段, 对 Insert/InsertAll/Upsert/UpsertAll 生效
+func (session *Session) OnDuplicateKeyUpdate(cols ...string) *Session {
+	session.duplicate = true
+	session.duplicateCols = append(session.duplicateCols, cols...)
+	return session
+}
+
+//插入时遇到唯一键冲突, 用 data 中的值更新: col=?, 值可以是 Expr, 可以和 OnDuplicateKeyUpdate 一起使用
+func (session *Session) UpdateWith(data map[string]interface{}) *Session {
+
+	session.duplicate = true
+
+	if session.duplicateData == nil {
+		session.duplicateData = make(map[string]interface{}, len(data))
+	}
+
+	for k, v := range data {
+		session.duplicateData[k] = v
+	}
+
+	return session
+}
+
+//插入一条数据, 唯一键冲突时更新, 没有设置 OnDuplicateKeyUpdate/UpdateWith 时更新所有插入的字段
+func (session *Session) Upsert(data map[string]interface{}) (UpsertResult, error) {
+
+	session.duplicate = true
+
+	lastInsertId, rowsAffected, err := session.insert(data)
+
+	return UpsertResult{LastInsertId: lastInsertId, RowsAffected: rowsAffected}, err
+}
+
+//批量 upsert, 规则同 Upsert, LastInsertId 为第一行新插入数据的 id
+func (session *Session) UpsertAll(datas []map[string]interface{}) (UpsertResult, error) {
+
+	session.duplicate = true
+
+	ret, err := session.insertAll(datas, false)
+
+	return UpsertResult{LastInsertId: ret.lastInsertId, RowsAffected: ret.rowsAffected}, err
+}
+
+//INSERT IGNORE 插入一条数据, 唯一键冲突时忽略, 返回自增 id, 忽略时为 0
+func (session *Session) InsertIgnore(data map[string]interface{}) (int64, error) {
+	session.verb = "INSERT IGNORE"
+	return session.Insert(data)
+}
+
+//INSERT IGNORE 批量插入, 返回实际插入的行数
+func (session *Session) InsertAllIgnore(datas []map[string]interface{}) (int64, error) {
+	session.verb = "INSERT IGNORE"
+	return session.InsertAll(datas)
+}
+
+//REPLACE 插入一条数据, 唯一键冲突时删除旧数据后插入, 返回自增 id
+func (session *Session) Replace(data map[string]interface{}) (int64, error) {
+	session.verb = "REPLACE"
+	return session.Insert(data)
+}
+
+//REPLACE 批量插入, 返回影响行数, 替换的行会算 2 次
+func (session *Session) ReplaceAll(datas []map[string]interface{}) (int64, error) {
+	session.verb = "REPLACE"
+	return session.InsertAll(datas)
+}
+
+//insert 语句开头的关键字
+func (session *Session) insertVerb() string {
+	if len(session.verb) < 1 {
+		return "INSERT"
+	}
+	return session.verb
+}
+
+//生成 ON DUPLICATE KEY UPDATE 子句, keys 为插入的字段
+func (session *Session) duplicateClause(keys []string) (string, []interface{}) {
+
+	if !session.duplicate {
+		return "", nil
+	}
+
+	cols := session.duplicateCols
+	if len(cols) < 1 && len(session.duplicateData) < 1 {
+		cols = keys
+	}
+
+	var sets []string
+	var args []interface{}
+
+	for _, col := range cols {
+		//UpdateWith 中指定了值的字段以指定的值为准
+		if _, ok := session.duplicateData[col]; ok {
+			continue
+		}
+		sets = append(sets, session.quote(col)+"=VALUES("+session.quote(col)+")")
+	}
+
+	for _, col := range sortedKeys(session.duplicateData) {
+		placeholder, valueArgs := valueSql(session.duplicateData[col])
+		sets = append(sets, session.quote(col)+"="+placeholder)
+		args = append(args, valueArgs...)
+	}
+
+	if len(sets) < 1 {
+		return "", nil
+	}
+
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ","), args
+}
+
+//ON DUPLICATE KEY UPDATE/INSERT IGNORE/REPLACE 只有 mysql 支持, 其他方言返回错误, 防止生成错误的 sql
+func (session *Session) checkUpsertDialect() error {
+
+	if !session.duplicate && len(session.verb) < 1 {
+		return nil
+	}
+
+	if _, ok := session.Engine.dialect.(mysqlDialect); ok {
+		return nil
+	}
+
+	return errors.New("ON DUPLICATE KEY UPDATE/INSERT IGNORE/REPLACE 只支持 mysql 方言")
+}
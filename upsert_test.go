@@ -0,0 +1,54 @@
+package zyorm
+
+import "testing"
+
+type upsertUser struct {
+	Id   int64
+	Name string
+}
+
+//有的行没有插入或者更新了已有的行时, 不能把推算的自增 id 写回结构体
+func TestInsertStructsIds(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		duplicate bool
+		verb      string
+		affected  int64
+		want      []int64
+	}{
+		{"全部插入", false, "", 3, []int64{10, 11, 12}},
+		{"影响行数少于插入的行数", false, "", 1, []int64{0, 0, 0}},
+		{"OnDuplicateKeyUpdate", true, "", 3, []int64{0, 0, 0}},
+		{"INSERT IGNORE", false, "INSERT IGNORE", 3, []int64{0, 0, 0}},
+		{"REPLACE", false, "REPLACE", 3, []int64{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db, d := openStub(t)
+			engine := NewEngineFromDB(db, MySQL)
+
+			d.result = stubResult{lastInsertId: 10, rowsAffected: tt.affected}
+
+			users := []upsertUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+			session := engine.NewSession()
+			if tt.duplicate {
+				session.OnDuplicateKeyUpdate("name")
+			}
+			session.verb = tt.verb
+
+			if _, err := session.InsertStructs(&users); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, user := range users {
+				if user.Id != tt.want[i] {
+					t.Errorf("users[%d].Id = %d, want %d", i, user.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}